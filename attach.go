@@ -0,0 +1,108 @@
+package lexec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+var errAttached = errors.New(`command is attached to already running process`)
+
+// Attach returns execution which is linked to already running process with
+// given pid, so it can be controlled via Signal and Process.
+//
+// Since process is not a child of the current process, its exit status can't
+// be obtained: Wait will block until process exits and will return nil
+// regardless of process exit code. Output of the process can't be captured.
+func Attach(pid int) (*Execution, error) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return nil, karma.Format(
+			err,
+			`can't find process with pid %d`,
+			pid,
+		)
+	}
+
+	execution := New(nil, &attachedCommand{process: process})
+	execution.started = true
+
+	return execution, nil
+}
+
+// Signal sends signal to the started command process.
+func (execution *Execution) Signal(signal os.Signal) error {
+	process := execution.Process()
+	if process == nil {
+		return karma.Format(
+			nil,
+			`can't send signal to not started or non-local command: %s`,
+			execution.String(),
+		)
+	}
+
+	err := process.Signal(signal)
+	if err != nil {
+		return karma.Describe("signal", signal).Format(
+			err,
+			`can't send signal to command: %s`,
+			execution.String(),
+		)
+	}
+
+	return nil
+}
+
+type attachedCommand struct {
+	process *os.Process
+}
+
+func (command *attachedCommand) GetArgs() []string {
+	return []string{fmt.Sprintf(`<pid %d>`, command.process.Pid)}
+}
+
+func (command *attachedCommand) Run() error {
+	return errAttached
+}
+
+func (command *attachedCommand) Start() error {
+	return errAttached
+}
+
+func (command *attachedCommand) Wait() error {
+	_, err := command.process.Wait()
+	if err == nil || !errors.Is(err, syscall.ECHILD) {
+		return err
+	}
+
+	// process is not our child, so it can't be waited and the only way to
+	// find out that it is finished is to check that it still exists
+	for command.process.Signal(syscall.Signal(0)) == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return nil
+}
+
+func (command *attachedCommand) SetStdin(io.Reader) {}
+
+func (command *attachedCommand) SetStdout(io.Writer) {}
+
+func (command *attachedCommand) SetStderr(io.Writer) {}
+
+func (command *attachedCommand) StdinPipe() (io.WriteCloser, error) {
+	return nil, errAttached
+}
+
+func (command *attachedCommand) StdoutPipe() (io.Reader, error) {
+	return nil, errAttached
+}
+
+func (command *attachedCommand) StderrPipe() (io.Reader, error) {
+	return nil, errAttached
+}
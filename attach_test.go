@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachCanSignalRunningProcess(t *testing.T) {
+	cmd := exec.Command(`sleep`, `10`)
+
+	err := cmd.Start()
+	assert.NoError(t, err)
+
+	execution, err := Attach(cmd.Process.Pid)
+	assert.NoError(t, err)
+
+	err = execution.Signal(syscall.SIGTERM)
+	assert.NoError(t, err)
+
+	err = cmd.Wait()
+	assert.Error(t, err)
+
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	assert.Equal(t, syscall.SIGTERM, status.Signal())
+}
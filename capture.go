@@ -0,0 +1,149 @@
+package lexec
+
+import (
+	"bytes"
+	"io"
+)
+
+// CaptureBuffer represents storage for captured command output, which is
+// returned by GetStdout or GetStderr.
+type CaptureBuffer interface {
+	io.Reader
+	io.Writer
+}
+
+// customWriter holds writer set via SetStdout or SetStderr, which can't be
+// read back.
+type customWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// Capture forces capturing of stdout and stderr into internal buffers even
+// if custom writers are set via SetStdout or SetStderr, so output is written
+// into both and Output, GetStdout and GetStderr still work.
+func (execution *Execution) Capture() *Execution {
+	execution.mustNotBeStarted(`Capture`)
+
+	execution.capture = true
+
+	return execution
+}
+
+func (execution *Execution) setupCapture() {
+	if !execution.capture {
+		return
+	}
+
+	execution.stdout = captureCustomWriter(execution.stdout)
+	execution.stderr = captureCustomWriter(execution.stderr)
+}
+
+func captureCustomWriter(writer io.ReadWriter) io.ReadWriter {
+	custom, ok := writer.(customWriter)
+	if !ok {
+		return writer
+	}
+
+	buffer := &bytes.Buffer{}
+
+	return customWriter{
+		Reader: buffer,
+		Writer: io.MultiWriter(custom.Writer, buffer),
+	}
+}
+
+// SetCaptureBuffer sets buffer which will be used to capture stdout instead
+// of default unbounded buffer. GetStdout will return given buffer.
+func (execution *Execution) SetCaptureBuffer(
+	buffer CaptureBuffer,
+) *Execution {
+	execution.mustNotBeStarted(`SetCaptureBuffer`)
+
+	execution.stdout = buffer
+
+	return execution
+}
+
+// SetStderrCaptureBuffer sets buffer which will be used to capture stderr
+// instead of default unbounded buffer. GetStderr will return given buffer.
+func (execution *Execution) SetStderrCaptureBuffer(
+	buffer CaptureBuffer,
+) *Execution {
+	execution.mustNotBeStarted(`SetStderrCaptureBuffer`)
+
+	execution.stderr = buffer
+
+	return execution
+}
+
+// RingBuffer is CaptureBuffer which retains only last written bytes up to
+// its size.
+type RingBuffer struct {
+	data   []byte
+	start  int
+	length int
+}
+
+// NewRingBuffer creates new RingBuffer which retains last size bytes.
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{
+		data: make([]byte, size),
+	}
+}
+
+// Write writes data into buffer, overwriting oldest data if buffer is full.
+func (buffer *RingBuffer) Write(data []byte) (int, error) {
+	size := len(data)
+	capacity := len(buffer.data)
+
+	if capacity == 0 {
+		return size, nil
+	}
+
+	if len(data) > capacity {
+		data = data[len(data)-capacity:]
+	}
+
+	for _, char := range data {
+		end := (buffer.start + buffer.length) % capacity
+		buffer.data[end] = char
+
+		if buffer.length < capacity {
+			buffer.length++
+		} else {
+			buffer.start = (buffer.start + 1) % capacity
+		}
+	}
+
+	return size, nil
+}
+
+// Read reads retained data from buffer.
+func (buffer *RingBuffer) Read(data []byte) (int, error) {
+	if buffer.length == 0 {
+		return 0, io.EOF
+	}
+
+	read := 0
+	for read < len(data) && buffer.length > 0 {
+		data[read] = buffer.data[buffer.start]
+
+		buffer.start = (buffer.start + 1) % len(buffer.data)
+		buffer.length--
+		read++
+	}
+
+	return read, nil
+}
+
+// Bytes returns copy of retained data without consuming it.
+func (buffer *RingBuffer) Bytes() []byte {
+	result := make([]byte, buffer.length)
+
+	for i := range result {
+		result[i] = buffer.data[(buffer.start+i)%len(buffer.data)]
+	}
+
+	return result
+}
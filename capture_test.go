@@ -0,0 +1,55 @@
+package lexec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBufferRetainsOnlyLastBytes(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1234567890; printf abcdef`),
+	).SetCaptureBuffer(NewRingBuffer(8))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	stdout, err := ioutil.ReadAll(execution.GetStdout())
+	assert.NoError(t, err)
+	assert.Equal(t, "0\nabcdef", string(stdout))
+}
+
+func TestRingBufferKeepsTailOfLargeWrite(t *testing.T) {
+	buffer := NewRingBuffer(4)
+
+	_, _ = buffer.Write([]byte(`ab`))
+	_, _ = buffer.Write([]byte(`cdefgh`))
+	assert.Equal(t, `efgh`, string(buffer.Bytes()))
+
+	_, _ = buffer.Write([]byte(`ij`))
+	assert.Equal(t, `ghij`, string(buffer.Bytes()))
+}
+
+func TestCaptureBuffersOutputAlongsideCustomWriters(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo out; echo err >&2`),
+	).
+		SetStdout(&stdout).
+		SetStderr(&stderr).
+		Capture()
+
+	capturedStdout, capturedStderr, err := execution.Output()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "out\n", string(capturedStdout))
+	assert.Equal(t, "err\n", string(capturedStderr))
+	assert.Equal(t, "out\n", stdout.String())
+	assert.Equal(t, "err\n", stderr.String())
+}
@@ -0,0 +1,339 @@
+package lexec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+)
+
+// StdoutLines starts command and returns channel which receives stdout lines
+// of the command. Channel is closed when stdout is exhausted, then error of
+// the command, if any, is sent to the error channel, which is closed
+// afterwards.
+//
+// If context is done before command is finished, command is killed and
+// context error is sent to the error channel.
+func (execution *Execution) StdoutLines(
+	ctx context.Context,
+) (<-chan string, <-chan error) {
+	var (
+		lines = make(chan string)
+		errs  = make(chan error, 1)
+	)
+
+	stdout, err := execution.TeePipe(Stdout)
+	if err == nil {
+		err = execution.Start()
+	}
+
+	if err != nil {
+		errs <- err
+
+		close(lines)
+		close(errs)
+
+		return lines, errs
+	}
+
+	go func() {
+		defer close(errs)
+
+		err := execution.streamLines(ctx, stdout, func(line string) bool {
+			select {
+			case lines <- line:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+
+		close(lines)
+
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return lines, errs
+}
+
+// streamLines passes lines read from given reader to the callback until
+// reader is exhausted or callback returns false, in the latter case command
+// is killed. Returns result of Wait or context error if context is done.
+func (execution *Execution) streamLines(
+	ctx context.Context,
+	reader io.Reader,
+	callback func(string) bool,
+) error {
+	var (
+		done     = make(chan error, 1)
+		finished = make(chan struct{})
+	)
+
+	go func() {
+		done <- execution.Wait()
+		close(finished)
+	}()
+
+	kill := func() {
+		_ = execution.Kill()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill()
+		case <-finished:
+		}
+	}()
+
+	readErr := readLines(reader, func(line string) bool {
+		if !callback(line) {
+			kill()
+
+			return false
+		}
+
+		return true
+	})
+
+	// reader must be drained, otherwise command will block on writing
+	_, _ = io.Copy(ioutil.Discard, reader)
+
+	err := <-done
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if readErr != nil {
+		return karma.Format(
+			readErr,
+			`can't read output of command: %s`,
+			execution.String(),
+		)
+	}
+
+	return nil
+}
+
+// readLines passes lines read from given reader to the callback until reader
+// is exhausted or callback returns false. Unlike bufio.Scanner, lines are not
+// limited in length. Trailing line without newline is passed too.
+func readLines(reader io.Reader, callback func(string) bool) error {
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+			if !callback(line) {
+				return nil
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// StdoutJSONLines starts command and returns channel which receives stdout
+// lines of the command decoded as JSON, one value per line. Every line is
+// decoded into fresh value returned by factory, which should return pointer,
+// e.g. `func() interface{} { return &Event{} }`. Empty lines are skipped.
+//
+// Channels are closed same way as for StdoutLines. If line can't be decoded,
+// command is killed and decoding error is sent to the error channel.
+func (execution *Execution) StdoutJSONLines(
+	ctx context.Context,
+	factory func() interface{},
+) (<-chan interface{}, <-chan error) {
+	var (
+		values = make(chan interface{})
+		errs   = make(chan error, 1)
+	)
+
+	stdout, err := execution.TeePipe(Stdout)
+	if err == nil {
+		err = execution.Start()
+	}
+
+	if err != nil {
+		errs <- err
+
+		close(values)
+		close(errs)
+
+		return values, errs
+	}
+
+	go func() {
+		defer close(errs)
+
+		var decodeErr error
+
+		err := execution.streamLines(ctx, stdout, func(line string) bool {
+			if strings.TrimSpace(line) == "" {
+				return true
+			}
+
+			value := factory()
+
+			decodeErr = json.Unmarshal([]byte(line), value)
+			if decodeErr != nil {
+				decodeErr = karma.Describe("line", line).Format(
+					decodeErr,
+					`can't decode stdout line of command: %s`,
+					execution.String(),
+				)
+
+				return false
+			}
+
+			select {
+			case values <- value:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+
+		close(values)
+
+		if decodeErr != nil {
+			err = decodeErr
+		}
+
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return values, errs
+}
+
+// StreamChannels starts command and returns channels which receive stdout
+// and stderr lines of the command, buffered by given number of lines. When
+// buffer is full, command blocks on writing output until lines are consumed,
+// so both channels should be read. Channels are closed when streams are
+// exhausted, then error of the command, if any, is sent to the done channel,
+// which is closed afterwards. Failure to read output is reported same way.
+func (execution *Execution) StreamChannels(
+	bufSize int,
+) (stdout, stderr <-chan string, done <-chan error) {
+	var (
+		stdoutLines = make(chan string, bufSize)
+		stderrLines = make(chan string, bufSize)
+		errs        = make(chan error, 1)
+	)
+
+	fail := func(err error) (<-chan string, <-chan string, <-chan error) {
+		errs <- err
+
+		close(stdoutLines)
+		close(stderrLines)
+		close(errs)
+
+		return stdoutLines, stderrLines, errs
+	}
+
+	stdoutReader, err := execution.TeePipe(Stdout)
+	if err != nil {
+		return fail(err)
+	}
+
+	stderrReader, err := execution.TeePipe(Stderr)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = execution.Start()
+	if err != nil {
+		return fail(err)
+	}
+
+	go execution.streamChannels(
+		map[io.Reader]chan string{
+			stdoutReader: stdoutLines,
+			stderrReader: stderrLines,
+		},
+		errs,
+	)
+
+	return stdoutLines, stderrLines, errs
+}
+
+func (execution *Execution) streamChannels(
+	channels map[io.Reader]chan string,
+	errs chan error,
+) {
+	defer close(errs)
+
+	var (
+		group sync.WaitGroup
+		done  = make(chan error, 1)
+
+		readErr   error
+		readMutex sync.Mutex
+	)
+
+	go func() {
+		done <- execution.Wait()
+	}()
+
+	for reader, lines := range channels {
+		group.Add(1)
+
+		go func(reader io.Reader, lines chan string) {
+			defer group.Done()
+			defer close(lines)
+
+			err := readLines(reader, func(line string) bool {
+				lines <- line
+
+				return true
+			})
+			if err != nil {
+				readMutex.Lock()
+				if readErr == nil {
+					readErr = err
+				}
+				readMutex.Unlock()
+			}
+
+			// reader must be drained, otherwise command will block on writing
+			_, _ = io.Copy(ioutil.Discard, reader)
+		}(reader, lines)
+	}
+
+	group.Wait()
+
+	err := <-done
+	if err == nil && readErr != nil {
+		err = karma.Format(
+			readErr,
+			`can't read output of command: %s`,
+			execution.String(),
+		)
+	}
+
+	if err != nil {
+		errs <- err
+	}
+}
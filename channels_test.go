@@ -0,0 +1,190 @@
+package lexec
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdoutLinesDeliversEachLine(t *testing.T) {
+	lines, errors := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1; echo 2; echo 3 >&2; echo 4`),
+	).StdoutLines(context.Background())
+
+	var received []string
+	for line := range lines {
+		received = append(received, line)
+	}
+
+	assert.Equal(t, []string{`1`, `2`, `4`}, received)
+	assert.NoError(t, <-errors)
+}
+
+func TestStdoutLinesDeliversRunError(t *testing.T) {
+	lines, errors := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1; exit 1`),
+	).StdoutLines(context.Background())
+
+	var received []string
+	for line := range lines {
+		received = append(received, line)
+	}
+
+	assert.Equal(t, []string{`1`}, received)
+	assert.True(t, IsExitStatus(<-errors))
+}
+
+func TestStdoutLinesKillsCommandWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lines, errors := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1; exec sleep 10`),
+	).StdoutLines(ctx)
+
+	started := time.Now()
+
+	assert.Equal(t, `1`, <-lines)
+	cancel()
+
+	for range lines {
+	}
+
+	assert.ErrorIs(t, <-errors, context.Canceled)
+	assert.True(t, time.Since(started) < 5*time.Second)
+}
+
+func TestStdoutJSONLinesDecodesEachLine(t *testing.T) {
+	type event struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	values, errors := NewExec(
+		nil,
+		exec.Command(
+			`printf`,
+			`{"id":1,"name":"a"}\n\n{"id":2,"name":"b"}\n`,
+		),
+	).StdoutJSONLines(context.Background(), func() interface{} {
+		return &event{}
+	})
+
+	var received []event
+	for value := range values {
+		received = append(received, *value.(*event))
+	}
+
+	assert.Equal(t, []event{{1, "a"}, {2, "b"}}, received)
+	assert.NoError(t, <-errors)
+}
+
+func TestStdoutJSONLinesReportsDecodeError(t *testing.T) {
+	values, errors := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo '{"id":1}'; echo oops; exec sleep 10`),
+	).StdoutJSONLines(context.Background(), func() interface{} {
+		return &map[string]int{}
+	})
+
+	started := time.Now()
+
+	var received []interface{}
+	for value := range values {
+		received = append(received, value)
+	}
+
+	assert.Len(t, received, 1)
+
+	err := <-errors
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `can't decode stdout line`)
+	assert.True(t, time.Since(started) < 5*time.Second)
+}
+
+func TestStreamChannelsDeliversLinesOfBothStreams(t *testing.T) {
+	stdout, stderr, done := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo o1; echo e1 >&2; echo o2; echo e2 >&2; exit 2`,
+		),
+	).StreamChannels(1)
+
+	var (
+		stdoutLines []string
+		stderrLines []string
+	)
+
+	for stdout != nil || stderr != nil {
+		select {
+		case line, ok := <-stdout:
+			if !ok {
+				stdout = nil
+				continue
+			}
+
+			stdoutLines = append(stdoutLines, line)
+
+		case line, ok := <-stderr:
+			if !ok {
+				stderr = nil
+				continue
+			}
+
+			stderrLines = append(stderrLines, line)
+		}
+	}
+
+	assert.Equal(t, []string{`o1`, `o2`}, stdoutLines)
+	assert.Equal(t, []string{`e1`, `e2`}, stderrLines)
+
+	err := <-done
+	assert.True(t, IsExitStatus(err))
+	assert.Equal(t, 2, GetExitStatus(err))
+}
+
+func TestStdoutLinesDeliversLongLines(t *testing.T) {
+	lines, errors := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`head -c 100000 /dev/zero | tr '\0' a; echo; echo 2`,
+		),
+	).StdoutLines(context.Background())
+
+	var received []string
+	for line := range lines {
+		received = append(received, line)
+	}
+
+	assert.Equal(t, []string{strings.Repeat(`a`, 100000), `2`}, received)
+	assert.NoError(t, <-errors)
+}
+
+func TestStreamChannelsReportsReadError(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sh`, `-c`, `echo 1; sleep 0.1`))
+
+	stdout, stderr, done := execution.StreamChannels(1)
+
+	// tee pipe is closed with error to simulate failed read
+	execution.tees[Stdout][0].CloseWithError(io.ErrUnexpectedEOF)
+
+	for range stdout {
+	}
+
+	for range stderr {
+	}
+
+	err := <-done
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `can't read output`)
+	assert.Contains(t, err.Error(), io.ErrUnexpectedEOF.Error())
+}
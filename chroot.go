@@ -0,0 +1,61 @@
+package lexec
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// SetChroot sets directory which will be used as root directory for the
+// command.
+//
+// Command path and working directory are resolved inside new root, so
+// command binary should be located inside given directory and working
+// directory of the command (exec.Cmd.Dir) should be specified relative to the
+// new root. Supported only on Linux and only for commands created via NewExec.
+func (execution *Execution) SetChroot(dir string) *Execution {
+	execution.mustNotBeStarted(`SetChroot`)
+
+	execution.chroot = dir
+
+	return execution
+}
+
+// Chroot returns directory set by SetChroot or error if chroot is not
+// supported on current platform.
+func (execution *Execution) Chroot() (string, error) {
+	if !chrootSupported {
+		return execution.chroot, karma.Format(
+			nil,
+			`chroot is not supported on this platform`,
+		)
+	}
+
+	return execution.chroot, nil
+}
+
+func (execution *Execution) setupChroot() error {
+	if execution.chroot == "" {
+		return nil
+	}
+
+	dir, err := execution.Chroot()
+	if err != nil {
+		return karma.Describe("chroot", dir).Format(
+			err,
+			`can't set root directory for command: %s`,
+			execution.String(),
+		)
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return karma.Format(
+			nil,
+			`chroot can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	setChroot(cmd.Cmd, dir)
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const chrootSupported = true
+
+func setChroot(cmd *exec.Cmd, dir string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Chroot = dir
+}
@@ -0,0 +1,58 @@
+package lexec
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChrootRunsCommandInsideGivenRoot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip(`chroot requires root privileges`)
+	}
+
+	compiler, err := exec.LookPath(`go`)
+	if err != nil {
+		t.Skip(`go compiler is required to build static binary`)
+	}
+
+	root, err := ioutil.TempDir("", "lexec")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(root)
+
+	err = ioutil.WriteFile(filepath.Join(root, `main.go`), []byte(`
+		package main
+
+		import (
+			"fmt"
+			"io/ioutil"
+		)
+
+		func main() {
+			data, _ := ioutil.ReadFile("/marker")
+			fmt.Print(string(data))
+		}
+	`), 0644)
+	assert.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(root, `marker`), []byte(`chroot`), 0644)
+	assert.NoError(t, err)
+
+	build := exec.Command(compiler, `build`, `-o`, `helper`, `main.go`)
+	build.Dir = root
+	build.Env = append(os.Environ(), `CGO_ENABLED=0`, `GO111MODULE=off`)
+
+	err = NewExec(nil, build).Run()
+	assert.NoError(t, err)
+
+	stdout, _, err := NewExec(nil, exec.Command(`/helper`)).
+		SetChroot(root).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, `chroot`, string(stdout))
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package lexec
+
+import (
+	"os/exec"
+)
+
+const chrootSupported = false
+
+func setChroot(cmd *exec.Cmd, dir string) {}
@@ -0,0 +1,26 @@
+package lexec
+
+import (
+	"time"
+)
+
+// clock abstracts time source used by timeout logic, so it can be replaced
+// in tests.
+type clock interface {
+	Now() time.Time
+	AfterFunc(duration time.Duration, fn func()) clockTimer
+}
+
+type clockTimer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(duration time.Duration, fn func()) clockTimer {
+	return time.AfterFunc(duration, fn)
+}
@@ -0,0 +1,188 @@
+package lexec
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+
+	scheduled chan struct{}
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	fn       func()
+	done     bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:       time.Unix(0, 0),
+		scheduled: make(chan struct{}, 1024),
+	}
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) AfterFunc(
+	duration time.Duration,
+	fn func(),
+) clockTimer {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	timer := &fakeTimer{
+		clock:    clock,
+		deadline: clock.now.Add(duration),
+		fn:       fn,
+	}
+
+	clock.timers = append(clock.timers, timer)
+	clock.scheduled <- struct{}{}
+
+	return timer
+}
+
+// Advance moves clock forward and fires all expired timers. Returns true if
+// any timer has been fired.
+func (clock *fakeClock) Advance(duration time.Duration) bool {
+	clock.mutex.Lock()
+
+	clock.now = clock.now.Add(duration)
+
+	var expired []*fakeTimer
+	for _, timer := range clock.timers {
+		if !timer.done && !timer.deadline.After(clock.now) {
+			timer.done = true
+			expired = append(expired, timer)
+		}
+	}
+
+	clock.mutex.Unlock()
+
+	for _, timer := range expired {
+		timer.fn()
+	}
+
+	return len(expired) > 0
+}
+
+// Next moves clock forward to the earliest pending timer and fires it along
+// with all other expired timers. Returns false if no timer has been fired.
+func (clock *fakeClock) Next() bool {
+	clock.mutex.Lock()
+
+	var next *fakeTimer
+	for _, timer := range clock.timers {
+		if !timer.done && (next == nil || timer.deadline.Before(next.deadline)) {
+			next = timer
+		}
+	}
+
+	clock.mutex.Unlock()
+
+	if next == nil {
+		return false
+	}
+
+	return clock.Advance(next.deadline.Sub(clock.Now()))
+}
+
+// advanceOnSchedule moves clock forward to every timer as soon as it is
+// scheduled, until returned function is called.
+func advanceOnSchedule(clock *fakeClock) func() {
+	var (
+		done    = make(chan struct{})
+		stopped = make(chan struct{})
+	)
+
+	go func() {
+		defer close(stopped)
+
+		for {
+			select {
+			case <-clock.scheduled:
+				clock.Next()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (timer *fakeTimer) Stop() bool {
+	timer.clock.mutex.Lock()
+	defer timer.clock.mutex.Unlock()
+
+	if timer.done {
+		return false
+	}
+
+	timer.done = true
+
+	return true
+}
+
+func TestStdinWriteTimeoutUsesClock(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	clock := newFakeClock()
+
+	execution := NewExec(
+		Loggerf(logger),
+		exec.Command(`sleep`, `10`),
+	).
+		SetStdin(bytes.NewReader(make([]byte, 1024*1024))).
+		SetStdinWriteTimeout(time.Hour)
+
+	execution.clock = clock
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	// pipe buffer can't hold all data, so one of writes stalls until its
+	// timeout is triggered by advancing clock
+	for {
+		<-clock.scheduled
+
+		if clock.Next() {
+			break
+		}
+	}
+
+	assert.NoError(t, execution.Kill())
+
+	err = execution.Wait()
+	assert.True(t, IsExitStatus(err))
+
+	assert.Contains(
+		t,
+		log,
+		`stdin  |  write timed out after 1h0m0s, stdin closed`,
+	)
+}
@@ -0,0 +1,55 @@
+package lexec
+
+import (
+	"time"
+)
+
+// RunWithDeadlinePartial runs command and kills it if it is not finished
+// within given duration. Output captured so far is returned in any case,
+// along with flag which indicates that deadline has been exceeded.
+//
+// If deadline is exceeded, error caused by killing command is not returned.
+// Only command process itself is killed, same as for SetIdleTimeout.
+func (execution *Execution) RunWithDeadlinePartial(
+	deadline time.Duration,
+) (stdout, stderr []byte, timedOut bool, err error) {
+	err = execution.Start()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	execution.deadline = deadline
+
+	timer := execution.clock.AfterFunc(deadline, func() {
+		execution.timeoutMutex.Lock()
+		execution.deadlineExceeded = true
+		execution.timeoutMutex.Unlock()
+
+		execution.kill()
+	})
+
+	err = execution.Wait()
+
+	timer.Stop()
+
+	execution.timeoutMutex.Lock()
+	timedOut = execution.deadlineExceeded
+	execution.timeoutMutex.Unlock()
+
+	if timedOut {
+		err = nil
+	}
+
+	execution.RangeStreams(func(data StreamData) bool {
+		switch data.Stream {
+		case Stdout:
+			stdout = append(stdout, data.Data...)
+		case Stderr:
+			stderr = append(stderr, data.Data...)
+		}
+
+		return true
+	})
+
+	return stdout, stderr, timedOut, err
+}
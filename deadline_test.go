@@ -0,0 +1,81 @@
+package lexec
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWithDeadlinePartialReturnsOutputOfHungCommand(t *testing.T) {
+	clock := newFakeClock()
+
+	var finishErr error
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo partial; echo warn >&2; exec sleep 10`),
+	).SetPostFinish(func(_ int, err error) {
+		finishErr = err
+	})
+
+	execution.clock = clock
+
+	type result struct {
+		stdout, stderr []byte
+		timedOut       bool
+		err            error
+	}
+
+	done := make(chan result)
+
+	go func() {
+		var result result
+
+		result.stdout, result.stderr, result.timedOut, result.err =
+			execution.RunWithDeadlinePartial(time.Minute)
+
+		done <- result
+	}()
+
+	// deadline timer is scheduled after start
+	<-clock.scheduled
+
+	for stream, line := range map[Stream]string{
+		Stdout: `partial`,
+		Stderr: `warn`,
+	} {
+		err := execution.WaitForLine(
+			context.Background(),
+			stream,
+			func(value string) bool {
+				return value == line
+			},
+		)
+		assert.NoError(t, err)
+	}
+
+	clock.Advance(time.Minute)
+
+	partial := <-done
+	assert.NoError(t, partial.err)
+	assert.True(t, partial.timedOut)
+	assert.Equal(t, "partial\n", string(partial.stdout))
+	assert.Equal(t, "warn\n", string(partial.stderr))
+
+	assert.Error(t, finishErr)
+	assert.Contains(t, finishErr.Error(), `deadline: 1m0s`)
+	assert.NotContains(t, finishErr.Error(), `idle timeout`)
+}
+
+func TestRunWithDeadlinePartialReturnsErrorOfFinishedCommand(t *testing.T) {
+	stdout, _, timedOut, err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo done; exit 2`),
+	).RunWithDeadlinePartial(10 * time.Second)
+	assert.False(t, timedOut)
+	assert.Equal(t, 2, GetExitStatus(err))
+	assert.Equal(t, "done\n", string(stdout))
+}
@@ -0,0 +1,27 @@
+package lexec
+
+import (
+	"io"
+	"log"
+	"os"
+)
+
+// DebugEnv is name of environment variable which enables logging of all
+// executions created with nil logger into stderr, so subprocess behavior can
+// be inspected without code changes. Logging is enabled when variable is set
+// to any non-empty value except "0". Loggers passed to New or NewExec are
+// not affected.
+const DebugEnv = `LEXEC_DEBUG`
+
+// debugOutput is replaced in tests.
+var debugOutput io.Writer = os.Stderr
+
+func getDefaultLogger() FieldsLogger {
+	if value := os.Getenv(DebugEnv); value != "" && value != "0" {
+		return LoggerfFields(
+			log.New(debugOutput, "lexec: ", log.LstdFlags).Printf,
+		)
+	}
+
+	return nil
+}
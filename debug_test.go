@@ -0,0 +1,45 @@
+package lexec
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugEnvEnablesDefaultLogging(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	defer func(original io.Writer) {
+		debugOutput = original
+	}(debugOutput)
+
+	debugOutput = output
+
+	t.Setenv(DebugEnv, `1`)
+
+	err := NewExec(nil, exec.Command(`echo`, `hello`)).Run()
+	assert.NoError(t, err)
+
+	assert.Contains(t, output.String(), `lexec: `)
+	assert.Contains(t, output.String(), `launch | echo hello`)
+	assert.Contains(t, output.String(), `stdout |  hello`)
+}
+
+func TestDebugEnvIsDisabledByDefault(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	defer func(original io.Writer) {
+		debugOutput = original
+	}(debugOutput)
+
+	debugOutput = output
+
+	t.Setenv(DebugEnv, ``)
+
+	err := NewExec(nil, exec.Command(`echo`, `hello`)).Run()
+	assert.NoError(t, err)
+	assert.Empty(t, output.String())
+}
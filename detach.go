@@ -0,0 +1,78 @@
+package lexec
+
+import (
+	"os"
+
+	"github.com/reconquest/karma-go"
+)
+
+// Detach makes command run in new session (new process group on Windows),
+// so it outlives current process. Stdin, stdout and stderr of the command
+// are connected directly to files set via SetStdinFile, SetStdoutFile and
+// SetStderrFile or to null device otherwise, so output is neither captured
+// nor logged. Supported only for commands created via NewExec.
+//
+// Calling Wait is not required after Start, but then caller is responsible
+// for reaping the process.
+//
+// Detach can't be combined with SetSetpgid or SetForeground, since session
+// leader can't be moved into another process group, Start returns error in
+// that case.
+func (execution *Execution) Detach() *Execution {
+	execution.mustNotBeStarted(`Detach`)
+
+	execution.detach = true
+
+	return execution
+}
+
+func (execution *Execution) setupDetach() error {
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return karma.Format(
+			nil,
+			`only local command can be detached: %s`,
+			execution.String(),
+		)
+	}
+
+	setDetached(cmd.Cmd)
+
+	stdin, err := execution.openFile(
+		getDetachedPath(execution.stdinFile),
+		os.O_RDONLY,
+	)
+	if err != nil {
+		return err
+	}
+
+	stdout, err := execution.openFile(
+		getDetachedPath(execution.stdoutFile),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+	)
+	if err != nil {
+		return err
+	}
+
+	stderr, err := execution.openFile(
+		getDetachedPath(execution.stderrFile),
+		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+	)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	return nil
+}
+
+func getDetachedPath(path string) string {
+	if path == "" {
+		return os.DevNull
+	}
+
+	return path
+}
@@ -0,0 +1,109 @@
+package lexec
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetachRunsCommandInNewSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `output`)
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `sleep 0.2; echo survived`),
+	).
+		SetStdoutFile(path).
+		Detach()
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	pid := execution.Process().Pid
+
+	pgid, err := syscall.Getpgid(pid)
+	assert.NoError(t, err)
+	assert.Equal(t, pid, pgid)
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "survived\n", string(data))
+
+	stdout, err := ioutil.ReadAll(execution.GetStdout())
+	assert.NoError(t, err)
+	assert.Empty(t, stdout)
+}
+
+func TestDetachedCommandOutlivesParent(t *testing.T) {
+	if path := os.Getenv(`LEXEC_TEST_DETACH_OUTPUT`); path != "" {
+		err := NewExec(
+			nil,
+			exec.Command(`sh`, `-c`, `sleep 0.3; echo survived`),
+		).
+			SetStdoutFile(path).
+			Detach().
+			Start()
+		if err != nil {
+			os.Exit(1)
+		}
+
+		// command is not waited, parent exits right away
+		os.Exit(0)
+	}
+
+	path := filepath.Join(t.TempDir(), `output`)
+
+	parent := exec.Command(
+		os.Args[0],
+		`-test.run=^TestDetachedCommandOutlivesParent$`,
+	)
+	parent.Env = append(os.Environ(), `LEXEC_TEST_DETACH_OUTPUT=`+path)
+
+	err := parent.Run()
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		data, _ := ioutil.ReadFile(path)
+
+		return string(data) == "survived\n"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestDetachCantBeCombinedWithSetpgid(t *testing.T) {
+	err := NewExec(nil, exec.Command(`true`)).
+		Detach().
+		SetSetpgid(true).
+		Start()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `new session can't be combined`)
+}
+
+func TestDetachStopsLogBufferOnStart(t *testing.T) {
+	logged := []Stream{}
+
+	logger := func(command []string, stream Stream, data []byte) {
+		logged = append(logged, stream)
+	}
+
+	execution := NewExec(logger, exec.Command(`true`)).
+		SetLogBuffer(10, LogOverflowBlock).
+		Detach()
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	assert.Nil(t, execution.logEvents)
+	assert.Equal(t, []Stream{Launch}, logged)
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,188 @@
+package lexec
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// LogEnv enables logging of command environment on launch. Every variable is
+// logged as separate event of Env stream after being passed through given
+// redact function, which should return value to log, so secrets can be
+// masked.
+func (execution *Execution) LogEnv(
+	redact func(key, value string) string,
+) *Execution {
+	execution.mustNotBeStarted(`LogEnv`)
+
+	execution.envRedact = redact
+
+	return execution
+}
+
+func (execution *Execution) logEnv() {
+	if execution.envRedact == nil {
+		return
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return
+	}
+
+	for _, item := range getEnv(cmd) {
+		key, value := splitEnv(item)
+
+		execution.log(Env, []byte(key+"="+execution.envRedact(key, value)))
+	}
+}
+
+// SetEnvMap sets environment of the command from given map, replacing
+// environment inherited from current process. Supported only for commands
+// created via NewExec.
+func (execution *Execution) SetEnvMap(env map[string]string) *Execution {
+	execution.mustNotBeStarted(`SetEnvMap`)
+
+	execution.envMap = env
+
+	return execution
+}
+
+// AddEnv adds variable to environment of the command, overriding existing
+// variable with the same key, either inherited or set via SetEnvMap.
+// Supported only for commands created via NewExec.
+func (execution *Execution) AddEnv(key, value string) *Execution {
+	execution.mustNotBeStarted(`AddEnv`)
+
+	execution.envOverrides = append(execution.envOverrides, key+"="+value)
+
+	return execution
+}
+
+func (execution *Execution) setupEnv() error {
+	if execution.envMap == nil && len(execution.envOverrides) == 0 {
+		return nil
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return karma.Format(
+			nil,
+			`environment can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	var env []string
+
+	if execution.envMap != nil {
+		env = []string{}
+
+		for key, value := range execution.envMap {
+			env = append(env, key+"="+value)
+		}
+
+		sort.Strings(env)
+	} else {
+		env = append(env, getEnv(cmd)...)
+	}
+
+	for _, override := range execution.envOverrides {
+		env = setEnv(env, override)
+	}
+
+	cmd.Env = env
+
+	return nil
+}
+
+// setEnv replaces all items with the same key as given item or appends item
+// if there are none.
+func setEnv(env []string, item string) []string {
+	key, _ := splitEnv(item)
+
+	var (
+		result   []string
+		replaced bool
+	)
+
+	for _, existing := range env {
+		if existingKey, _ := splitEnv(existing); existingKey != key {
+			result = append(result, existing)
+		} else if !replaced {
+			result = append(result, item)
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// ExpandEnv enables expansion of $VAR and ${VAR} references in command
+// arguments against command environment (or current process environment if
+// command environment is not set) right before launch. Undefined variables
+// are expanded to empty string. Expanded arguments are passed only to the
+// started process, command is logged and reported with arguments as is, so
+// values of variables, e.g. secrets, are not exposed.
+//
+// Only simple variable references are expanded, there is no globbing,
+// quoting, default values or any other shell features.
+func (execution *Execution) ExpandEnv() *Execution {
+	execution.mustNotBeStarted(`ExpandEnv`)
+
+	execution.expandEnv = true
+
+	return execution
+}
+
+// getExpandedArgs returns copy of command arguments expanded against command
+// environment or nil if expansion is not enabled. Arguments of the command
+// itself are not changed, so expanded values are neither logged nor reported.
+func (execution *Execution) getExpandedArgs() []string {
+	if !execution.expandEnv {
+		return nil
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return nil
+	}
+
+	env := map[string]string{}
+	for _, item := range getEnv(cmd) {
+		key, value := splitEnv(item)
+
+		env[key] = value
+	}
+
+	args := append([]string(nil), cmd.Args...)
+	for i := 1; i < len(args); i++ {
+		args[i] = os.Expand(args[i], func(key string) string {
+			return env[key]
+		})
+	}
+
+	return args
+}
+
+func getEnv(cmd *command) []string {
+	if cmd.Env == nil {
+		return os.Environ()
+	}
+
+	return cmd.Env
+}
+
+func splitEnv(item string) (string, string) {
+	if index := strings.Index(item, "="); index >= 0 {
+		return item[:index], item[index+1:]
+	}
+
+	return item, ""
+}
@@ -0,0 +1,91 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogEnvRedactsSecrets(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	cmd := exec.Command(`true`)
+	cmd.Env = []string{`PATH=/usr/bin:/bin`, `PASSWORD=hunter2`}
+
+	execution := NewExec(Loggerf(logger), cmd).
+		LogEnv(func(key, value string) string {
+			if key == `PASSWORD` {
+				return `***`
+			}
+
+			return value
+		})
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | true`,
+		`env    |  PATH=/usr/bin:/bin`,
+		`env    |  PASSWORD=***`,
+		`finish | true -> exit 0`,
+	}, log)
+}
+
+func TestExpandEnvExpandsArguments(t *testing.T) {
+	cmd := exec.Command(`echo`, `$HOME/a`, `${HOME}`, `$LEXEC_UNDEFINED.`)
+	cmd.Env = []string{`HOME=/home/lexec`}
+
+	stdout, _, err := NewExec(nil, cmd).ExpandEnv().Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/lexec/a /home/lexec .\n", string(stdout))
+}
+
+func TestExpandEnvDoesNotExposeExpandedArguments(t *testing.T) {
+	var launched []string
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Launch {
+			launched = command
+		}
+	}
+
+	cmd := exec.Command(`echo`, `$LEXEC_SECRET`)
+	cmd.Env = []string{`LEXEC_SECRET=password`}
+
+	execution := NewExec(logger, cmd).ExpandEnv()
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "password\n", string(stdout))
+
+	assert.Equal(t, []string{`echo`, `$LEXEC_SECRET`}, launched)
+	assert.Equal(t, []string{`echo`, `$LEXEC_SECRET`}, cmd.Args)
+	assert.NotContains(t, execution.String(), `password`)
+}
+
+func TestAddEnvOverridesInheritedVariable(t *testing.T) {
+	t.Setenv(`LEXEC_TEST`, `inherited`)
+
+	stdout, err := NewExec(nil, exec.Command(`sh`, `-c`, `env | grep ^LEXEC_`)).
+		AddEnv(`LEXEC_TEST`, `overridden`).
+		AddEnv(`LEXEC_ADDED`, `added`).
+		Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, "LEXEC_TEST=overridden\nLEXEC_ADDED=added\n", stdout)
+}
+
+func TestSetEnvMapReplacesEnvironment(t *testing.T) {
+	stdout, err := NewExec(nil, exec.Command(`env`)).
+		SetEnvMap(map[string]string{`B`: `2`, `A`: `1`}).
+		AddEnv(`B`, `3`).
+		Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, "A=1\nB=3\n", stdout)
+}
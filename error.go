@@ -1,24 +1,164 @@
 package lexec
 
-import "github.com/reconquest/karma-go"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/reconquest/karma-go"
+)
+
+// ErrNotStarted is returned by Wait when command has not been started.
+var ErrNotStarted = errors.New(`command is not started`)
 
 // ExitStatusError is returned when a command exists with non-zero exit code.
 type ExitStatusError struct {
 	karma.Karma
 	ExitStatus int
+
+	// Stdout and Stderr hold output captured from the command.
+	Stdout []byte
+	Stderr []byte
+
+	// Truncated is true if Stdout and Stderr hold only part of output, see
+	// SpillToDiskAfter.
+	Truncated bool
+
+	mapped error
+}
+
+// Unwrap returns error produced by mapper set via SetExitCodeMapper, so it
+// can be matched using errors.Is and errors.As.
+func (err ExitStatusError) Unwrap() error {
+	return err.mapped
 }
 
 // IsExitStatus returns true if the given error is an instance of
-// ExitStatusError.
+// ExitStatusError or wraps it, either via errors wrapping or as a reason in
+// karma chain.
 func IsExitStatus(err error) bool {
-	_, ok := err.(ExitStatusError)
+	_, ok := findExitStatusError(err)
 	return ok
 }
 
-// GetExitStatus returns an exitcode of the given ExitStatusError.
+// GetExitStatus returns an exitcode of the given ExitStatusError, which can
+// be wrapped same way as for IsExitStatus.
 func GetExitStatus(err error) int {
-	if err, ok := err.(ExitStatusError); ok {
+	if err, ok := findExitStatusError(err); ok {
 		return err.ExitStatus
 	}
 	return 0
 }
+
+func findExitStatusError(err error) (ExitStatusError, bool) {
+	var exitErr ExitStatusError
+	if errors.As(err, &exitErr) {
+		return exitErr, true
+	}
+
+	var chain karma.Karma
+	if !errors.As(err, &chain) {
+		return exitErr, false
+	}
+
+	for _, reason := range chain.GetReasons() {
+		if reason, ok := reason.(error); ok {
+			if exitErr, ok := findExitStatusError(reason); ok {
+				return exitErr, true
+			}
+		}
+	}
+
+	return exitErr, false
+}
+
+var startErrorHints = map[syscall.Errno]string{
+	syscall.EACCES: `permission denied, check that file has executable ` +
+		`bit set (chmod +x) and is not located on noexec mount`,
+	syscall.ETXTBSY: `text file busy, file is still opened for writing, ` +
+		`close it before running or retry later`,
+	syscall.ENOEXEC: `exec format error, file is not a binary for this ` +
+		`platform or script lacks shebang line (#!/bin/sh)`,
+}
+
+func describeStartError(err error) error {
+	var errno syscall.Errno
+
+	if !errors.As(err, &errno) {
+		return err
+	}
+
+	hint, ok := startErrorHints[errno]
+	if !ok {
+		return err
+	}
+
+	return karma.Format(err, hint)
+}
+
+// CommandNotFoundError is returned by CheckExists when command binary can't
+// be resolved.
+type CommandNotFoundError struct {
+	karma.Karma
+	Name string
+}
+
+// FriendlyError returns concise single-line message for given error, which
+// is suitable for showing to end users.
+//
+// For ExitStatusError message includes command, exit code and trimmed
+// stderr output (or stdout if stderr is empty). For other karma errors
+// messages of the chain are joined without context.
+func FriendlyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if exitErr, ok := findExitStatusError(err); ok {
+		var command interface{}
+
+		exitErr.Context.Walk(func(key string, value interface{}) {
+			if key == "command" {
+				command = value
+			}
+		})
+
+		message := fmt.Sprintf(
+			`%v exited with code %d`,
+			command,
+			exitErr.ExitStatus,
+		)
+
+		output := exitErr.Stderr
+		if len(strings.TrimSpace(string(output))) == 0 {
+			output = exitErr.Stdout
+		}
+
+		if lines := strings.Fields(string(output)); len(lines) > 0 {
+			message += ": " + strings.Join(lines, " ")
+		}
+
+		return message
+	}
+
+	return friendlyReason(err)
+}
+
+func friendlyReason(reason karma.Reason) string {
+	chain, ok := reason.(karma.Karma)
+	if !ok {
+		return strings.Join(strings.Fields(fmt.Sprint(reason)), " ")
+	}
+
+	messages := []string{}
+	if chain.Message != "" {
+		messages = append(messages, chain.Message)
+	}
+
+	for _, nested := range chain.GetReasons() {
+		messages = append(messages, friendlyReason(nested))
+	}
+
+	return strings.Join(messages, ": ")
+}
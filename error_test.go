@@ -0,0 +1,142 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/reconquest/karma-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartExplainsPermissionDeniedError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lexec")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "script")
+
+	err = ioutil.WriteFile(path, []byte("#!/bin/sh\necho 1\n"), 0644)
+	assert.NoError(t, err)
+
+	err = NewExec(nil, exec.Command(path)).Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `can't start command`)
+	assert.Contains(t, err.Error(), `chmod +x`)
+}
+
+func TestStartExplainsExecFormatError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lexec")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "script")
+
+	err = ioutil.WriteFile(path, []byte("echo 1\n"), 0755)
+	assert.NoError(t, err)
+
+	err = NewExec(nil, exec.Command(path)).Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `shebang`)
+}
+
+var errConfigInvalid = errors.New(`config is invalid`)
+
+func TestExitCodeMapperTranslatesExitCode(t *testing.T) {
+	mapper := func(code int) error {
+		if code == 2 {
+			return errConfigInvalid
+		}
+
+		return nil
+	}
+
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `echo bad key; exit 2`)).
+		SetExitCodeMapper(mapper).
+		Run()
+	assert.True(t, errors.Is(err, errConfigInvalid))
+	assert.Equal(t, 2, GetExitStatus(err))
+	assert.Contains(t, err.Error(), `config is invalid`)
+	assert.Contains(t, err.Error(), `bad key`)
+
+	err = NewExec(nil, exec.Command(`sh`, `-c`, `exit 3`)).
+		SetExitCodeMapper(mapper).
+		Run()
+	assert.False(t, errors.Is(err, errConfigInvalid))
+	assert.Contains(
+		t,
+		err.Error(),
+		`execution completed with non-zero exit code`,
+	)
+}
+
+func TestExitStatusHelpersWorkThroughWrapping(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `exit 5`)).Run()
+	assert.True(t, IsExitStatus(err))
+
+	wrapped := []error{
+		fmt.Errorf(`deploy failed: %w`, err),
+		karma.Format(err, `deploy failed`),
+		karma.Format(karma.Format(err, `step failed`), `deploy failed`),
+		karma.Push(`deploy failed`, errors.New(`other`), err),
+	}
+
+	for _, err := range wrapped {
+		assert.True(t, IsExitStatus(err), err.Error())
+		assert.Equal(t, 5, GetExitStatus(err), err.Error())
+	}
+
+	assert.False(t, IsExitStatus(karma.Format(nil, `deploy failed`)))
+	assert.Equal(t, 0, GetExitStatus(errors.New(`deploy failed`)))
+}
+
+func TestExitStatusErrorCarriesOutput(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo out; echo err >&2; exit 1`),
+	).Run()
+
+	exitErr, ok := err.(ExitStatusError)
+	assert.True(t, ok)
+	assert.Equal(t, "out\n", string(exitErr.Stdout))
+	assert.Equal(t, "err\n", string(exitErr.Stderr))
+}
+
+func TestFriendlyErrorReturnsConciseMessage(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo noise; echo fatal: bad >&2; echo "  config" >&2; exit 2`,
+		),
+	).
+		SetStringFormat(FormatShellCommand).
+		Run()
+
+	assert.Equal(
+		t,
+		`sh -c "echo noise; echo fatal: bad >&2; echo \"  config\" >&2; `+
+			`exit 2" exited with code 2: fatal: bad config`,
+		FriendlyError(err),
+	)
+
+	err = NewExec(nil, exec.Command(`lexec-nonexistent-command`)).Run()
+
+	assert.NotContains(t, FriendlyError(err), "\n")
+	assert.Contains(
+		t,
+		FriendlyError(err),
+		`can't start command: ["lexec-nonexistent-command"]: exec:`,
+	)
+
+	assert.Equal(t, ``, FriendlyError(nil))
+}
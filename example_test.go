@@ -12,7 +12,7 @@ import (
 	"github.com/reconquest/lexec-go"
 )
 
-func ExampleLoggedExec() {
+func ExampleNewExec() {
 	logger := log.New(os.Stdout, `LOG: `, 0)
 
 	cmd := lexec.NewExec(
@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func getExitStatus(err *exec.ExitError) (int, bool) {
+	status, ok := err.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 0, false
+	}
+
+	return status.ExitStatus(), true
+}
+
+func getExitSignal(err *exec.ExitError) (syscall.Signal, bool) {
+	status, ok := err.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+
+	return status.Signal(), true
+}
@@ -0,0 +1,20 @@
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func getExitStatus(err *exec.ExitError) (int, bool) {
+	if err.ProcessState == nil {
+		return 0, false
+	}
+
+	return err.ProcessState.ExitCode(), true
+}
+
+// getExitSignal always reports false, since processes on Windows are not
+// terminated by signals.
+func getExitSignal(err *exec.ExitError) (syscall.Signal, bool) {
+	return 0, false
+}
@@ -0,0 +1,14 @@
+package lexec
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportsNonZeroExitStatusOnWindows(t *testing.T) {
+	err := NewExec(nil, exec.Command(`cmd`, `/c`, `exit 3`)).Run()
+	assert.True(t, IsExitStatus(err))
+	assert.Equal(t, 3, GetExitStatus(err))
+}
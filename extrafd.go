@@ -0,0 +1,121 @@
+package lexec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/reconquest/callbackwriter-go"
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/lineflushwriter-go"
+	"github.com/reconquest/nopio-go"
+)
+
+// CaptureExtraFd sets up pipe which is passed to the command as extra file
+// descriptor with given number (3 or more) and returns reader for it. Data
+// read from reader is logged line by line as `fdN` stream.
+//
+// Reader should be read until EOF concurrently with Wait, same as
+// StdoutPipe. It is closed automatically when EOF is reached or when Start
+// fails. Extra file descriptors are not supported on Windows.
+func (execution *Execution) CaptureExtraFd(fd int) (io.Reader, error) {
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return nil, karma.Format(
+			nil,
+			`can't capture extra fd of non-local command: %s`,
+			execution.String(),
+		)
+	}
+
+	if fd < 3 {
+		return nil, karma.Describe("fd", fd).Format(
+			nil,
+			`extra fd should be 3 or more`,
+		)
+	}
+
+	if execution.started {
+		return nil, karma.Format(
+			nil,
+			`can't capture extra fd of already started command: %s`,
+			execution.String(),
+		)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, karma.Describe("fd", fd).Format(
+			err,
+			`can't create pipe for extra fd`,
+		)
+	}
+
+	for len(cmd.ExtraFiles) <= fd-3 {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, nil)
+	}
+
+	cmd.ExtraFiles[fd-3] = writer
+
+	execution.extraFdWriters = append(execution.extraFdWriters, writer)
+	execution.extraFdReaders = append(execution.extraFdReaders, reader)
+
+	stream := Stream(fmt.Sprintf("fd%d", fd))
+
+	return &extraFdReader{
+		file: reader,
+		logger: lineflushwriter.New(
+			callbackwriter.New(
+				nopio.NopWriteCloser{},
+				func(data []byte) {
+					execution.logUnlocked(stream, bytes.TrimRight(data, "\n"))
+				},
+				nil,
+			),
+			execution.logMutex,
+			true,
+		),
+	}, nil
+}
+
+// closeExtraFdWriters closes write ends of extra fd pipes in parent process
+// once command has got own copies, so readers observe EOF when command exits.
+func (execution *Execution) closeExtraFdWriters() {
+	for _, writer := range execution.extraFdWriters {
+		_ = writer.Close()
+	}
+
+	execution.extraFdWriters = nil
+}
+
+// closeExtraFds closes both ends of extra fd pipes when command can't be
+// started, so readers returned by CaptureExtraFd do not block forever.
+func (execution *Execution) closeExtraFds() {
+	execution.closeExtraFdWriters()
+
+	for _, reader := range execution.extraFdReaders {
+		_ = reader.Close()
+	}
+
+	execution.extraFdReaders = nil
+}
+
+type extraFdReader struct {
+	file   *os.File
+	logger io.WriteCloser
+}
+
+func (reader *extraFdReader) Read(data []byte) (int, error) {
+	size, err := reader.file.Read(data)
+	if size > 0 {
+		_, _ = reader.logger.Write(data[:size])
+	}
+
+	if err == io.EOF {
+		_ = reader.logger.Close()
+		_ = reader.file.Close()
+	}
+
+	return size, err
+}
@@ -0,0 +1,59 @@
+package lexec
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureExtraFdReadsDataWrittenToFd3(t *testing.T) {
+	logged := []string{}
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == `fd3` {
+			logged = append(logged, string(data))
+		}
+	}
+
+	execution := NewExec(
+		logger,
+		exec.Command(`sh`, `-c`, `echo '{"a":1}' >&3; echo out`),
+	)
+
+	reader, err := execution.CaptureExtraFd(3)
+	assert.NoError(t, err)
+
+	err = execution.Start()
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "{\"a\":1}\n", string(data))
+	assert.Equal(t, []string{`{"a":1}`}, logged)
+	assert.Equal(t, "[stdout] out", execution.StreamsString())
+}
+
+func TestCaptureExtraFdRejectsStandardFds(t *testing.T) {
+	_, err := NewExec(nil, exec.Command(`true`)).CaptureExtraFd(1)
+	assert.Error(t, err)
+}
+
+func TestCaptureExtraFdReaderIsClosedWhenStartFails(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sh`, `-c`, `echo 1 >&3`)).
+		SetPath(t.TempDir())
+
+	reader, err := execution.CaptureExtraFd(3)
+	assert.NoError(t, err)
+
+	err = execution.Start()
+	assert.Error(t, err)
+
+	_, err = ioutil.ReadAll(reader)
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package lexec
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/reconquest/karma-go"
+)
+
+// SetStdoutFile sets file which will be used to store stdout. File is
+// created (or truncated) on Start and closed by Wait or Close.
+func (execution *Execution) SetStdoutFile(path string) *Execution {
+	execution.mustNotBeStarted(`SetStdoutFile`)
+
+	execution.stdoutFile = path
+
+	return execution
+}
+
+// SetStderrFile sets file which will be used to store stderr. File is
+// created (or truncated) on Start and closed by Wait or Close.
+func (execution *Execution) SetStderrFile(path string) *Execution {
+	execution.mustNotBeStarted(`SetStderrFile`)
+
+	execution.stderrFile = path
+
+	return execution
+}
+
+// SetStdinFile sets file which will be used as stdin. File is opened on
+// Start and closed by Wait or Close.
+func (execution *Execution) SetStdinFile(path string) *Execution {
+	execution.mustNotBeStarted(`SetStdinFile`)
+
+	execution.stdinFile = path
+
+	return execution
+}
+
+// SetStdoutGzipFile sets file which will be used to store gzip-compressed
+// stdout. File is created (or truncated) on Start, compressed stream is
+// finished and file is closed by Wait or Close.
+func (execution *Execution) SetStdoutGzipFile(path string) *Execution {
+	execution.mustNotBeStarted(`SetStdoutGzipFile`)
+
+	execution.stdoutGzipFile = path
+
+	return execution
+}
+
+// SetStderrGzipFile sets file which will be used to store gzip-compressed
+// stderr. File is created (or truncated) on Start, compressed stream is
+// finished and file is closed by Wait or Close.
+func (execution *Execution) SetStderrGzipFile(path string) *Execution {
+	execution.mustNotBeStarted(`SetStderrGzipFile`)
+
+	execution.stderrGzipFile = path
+
+	return execution
+}
+
+// Close releases resources associated with the execution: flushes logged
+// output, closes stdin pipe and files opened for SetStdoutFile, SetStderrFile,
+// SetStdinFile and gzip variants, removes temporary files created for SpillToDiskAfter.
+// It is safe to call Close after Wait and several times.
+func (execution *Execution) Close() error {
+	if execution.closer != nil {
+		execution.closer()
+	}
+
+	if execution.stdin != nil && execution.stdinPipe {
+		_ = execution.stdin.Close()
+	}
+
+	execution.closeExtraFdWriters()
+
+	err := execution.closeFiles()
+
+	spillErr := execution.closeSpillBuffers()
+	if err != nil {
+		return err
+	}
+
+	return spillErr
+}
+
+func (execution *Execution) setupFiles() error {
+	if execution.stdoutFile != "" {
+		file, err := execution.openFile(
+			execution.stdoutFile,
+			os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		)
+		if err != nil {
+			return err
+		}
+
+		execution.SetStdout(file)
+	}
+
+	if execution.stderrFile != "" {
+		file, err := execution.openFile(
+			execution.stderrFile,
+			os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		)
+		if err != nil {
+			return err
+		}
+
+		execution.SetStderr(file)
+	}
+
+	if execution.stdoutGzipFile != "" {
+		writer, err := execution.openGzipFile(execution.stdoutGzipFile)
+		if err != nil {
+			return err
+		}
+
+		execution.SetStdout(writer)
+	}
+
+	if execution.stderrGzipFile != "" {
+		writer, err := execution.openGzipFile(execution.stderrGzipFile)
+		if err != nil {
+			return err
+		}
+
+		execution.SetStderr(writer)
+	}
+
+	if execution.stdinFile != "" {
+		file, err := execution.openFile(execution.stdinFile, os.O_RDONLY)
+		if err != nil {
+			return err
+		}
+
+		execution.SetStdin(file)
+	}
+
+	return nil
+}
+
+func (execution *Execution) openFile(
+	path string,
+	flag int,
+) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		_ = execution.closeFiles()
+
+		return nil, karma.Format(
+			err,
+			`can't open file for command: %s`,
+			execution.String(),
+		)
+	}
+
+	execution.files = append(execution.files, file)
+
+	return file, nil
+}
+
+func (execution *Execution) openGzipFile(path string) (*gzip.Writer, error) {
+	file, err := execution.openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := gzip.NewWriter(file)
+
+	execution.gzipWriters = append(execution.gzipWriters, writer)
+
+	return writer, nil
+}
+
+func (execution *Execution) closeFiles() error {
+	var result error
+
+	for _, writer := range execution.gzipWriters {
+		err := writer.Close()
+		if err != nil && result == nil {
+			result = karma.Format(err, `can't finish gzip stream`)
+		}
+	}
+
+	execution.gzipWriters = nil
+
+	for _, file := range execution.files {
+		err := file.Close()
+		if err != nil && result == nil {
+			result = karma.Format(
+				err,
+				`can't close file %s`,
+				file.Name(),
+			)
+		}
+	}
+
+	execution.files = nil
+
+	return result
+}
+
+var _ io.Closer = (*Execution)(nil)
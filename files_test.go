@@ -0,0 +1,92 @@
+package lexec
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseClosesOpenedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lexec")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, `input`)
+	output := filepath.Join(dir, `output`)
+
+	err = ioutil.WriteFile(input, []byte("1\n2\n"), 0644)
+	assert.NoError(t, err)
+
+	execution := NewExec(nil, exec.Command(`cat`)).
+		SetStdinFile(input).
+		SetStdoutFile(output)
+
+	defer execution.Close()
+
+	err = execution.Start()
+	assert.NoError(t, err)
+
+	files := append([]*os.File{}, execution.files...)
+	assert.Len(t, files, 2)
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+
+	err = execution.Close()
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		_, err := file.Stat()
+		assert.ErrorIs(t, err, os.ErrClosed)
+	}
+
+	data, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n", string(data))
+}
+
+func TestCloseCanBeCalledWithoutRun(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`true`)).
+		SetStdoutFile(`/nonexistent/output`)
+
+	assert.NoError(t, execution.Close())
+	assert.Error(t, execution.Run())
+	assert.NoError(t, execution.Close())
+}
+
+func TestSetStdoutGzipFileWritesCompressedOutput(t *testing.T) {
+	dir := t.TempDir()
+
+	stdout := filepath.Join(dir, `stdout.gz`)
+	stderr := filepath.Join(dir, `stderr.gz`)
+
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `seq 3; echo err >&2`)).
+		SetStdoutGzipFile(stdout).
+		SetStderrGzipFile(stderr).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1\n2\n3\n", readGzipFile(t, stdout))
+	assert.Equal(t, "err\n", readGzipFile(t, stderr))
+}
+
+func readGzipFile(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+
+	return string(data)
+}
@@ -9,6 +9,7 @@ require (
 	github.com/reconquest/lineflushwriter-go v0.0.0-20200921103343-b9b8d10a6851
 	github.com/reconquest/nopio-go v0.0.0-20161213101805-20796acb207f
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/text v0.22.0
 )
 
 require (
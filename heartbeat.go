@@ -0,0 +1,62 @@
+package lexec
+
+import (
+	"time"
+)
+
+// SetHeartbeat sets callback which is called periodically with given
+// interval while command is running. Callback receives duration elapsed since
+// command has been started. Heartbeat is stopped when command finishes.
+func (execution *Execution) SetHeartbeat(
+	interval time.Duration,
+	callback func(elapsed time.Duration),
+) *Execution {
+	execution.mustNotBeStarted(`SetHeartbeat`)
+
+	execution.heartbeatInterval = interval
+	execution.heartbeat = callback
+
+	return execution
+}
+
+func (execution *Execution) startHeartbeat() {
+	if execution.heartbeat == nil || execution.heartbeatInterval <= 0 {
+		return
+	}
+
+	execution.heartbeatMutex.Lock()
+	defer execution.heartbeatMutex.Unlock()
+
+	execution.scheduleHeartbeat()
+}
+
+// scheduleHeartbeat should be called with heartbeatMutex held.
+func (execution *Execution) scheduleHeartbeat() {
+	execution.heartbeatTimer = execution.clock.AfterFunc(
+		execution.heartbeatInterval,
+		func() {
+			execution.heartbeatMutex.Lock()
+			defer execution.heartbeatMutex.Unlock()
+
+			if execution.heartbeatTimer == nil {
+				return
+			}
+
+			execution.heartbeat(
+				execution.clock.Now().Sub(execution.startedAt),
+			)
+
+			execution.scheduleHeartbeat()
+		},
+	)
+}
+
+func (execution *Execution) stopHeartbeat() {
+	execution.heartbeatMutex.Lock()
+	defer execution.heartbeatMutex.Unlock()
+
+	if execution.heartbeatTimer != nil {
+		execution.heartbeatTimer.Stop()
+		execution.heartbeatTimer = nil
+	}
+}
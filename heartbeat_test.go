@@ -0,0 +1,52 @@
+package lexec
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeatFiresWhileCommandIsRunning(t *testing.T) {
+	var (
+		beats []time.Duration
+		mutex sync.Mutex
+	)
+
+	clock := newFakeClock()
+
+	execution := NewExec(nil, exec.Command(`cat`)).
+		SetHeartbeat(time.Minute, func(elapsed time.Duration) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			beats = append(beats, elapsed)
+		})
+
+	execution.clock = clock
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		<-clock.scheduled
+		clock.Advance(time.Minute)
+	}
+
+	assert.NoError(t, execution.GetStdin().Close())
+	assert.NoError(t, execution.Wait())
+
+	clock.Advance(time.Hour)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	assert.Equal(
+		t,
+		[]time.Duration{time.Minute, 2 * time.Minute},
+		beats,
+		`heartbeat should stop after finish`,
+	)
+}
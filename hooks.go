@@ -0,0 +1,77 @@
+package lexec
+
+// SetPreStart sets hook which is called by Start before spawning the command,
+// after environment, path and argument expansion are applied, so hook sees
+// argv and environment exactly as command will receive them. If hook returns
+// error, Start is aborted and returns that error as is.
+//
+// Environment and working directory are passed only for commands created via
+// NewExec, nil environment means that environment of the current process is
+// inherited.
+func (execution *Execution) SetPreStart(
+	hook func(argv []string, env []string, dir string) error,
+) *Execution {
+	execution.mustNotBeStarted(`SetPreStart`)
+
+	execution.preStart = hook
+
+	return execution
+}
+
+func (execution *Execution) runPreStart() error {
+	if execution.preStart == nil {
+		return nil
+	}
+
+	var (
+		env []string
+		dir string
+	)
+
+	if cmd, ok := execution.command.(*command); ok {
+		env = cmd.Env
+		dir = cmd.Dir
+	}
+
+	argv := execution.getExpandedArgs()
+	if argv == nil {
+		argv = execution.command.GetArgs()
+	}
+
+	return execution.preStart(argv, env, dir)
+}
+
+// SetPostFinish sets hook which is called once at the end of Wait after
+// finish is logged, regardless of execution result. Exit code is exit code of
+// the process even if Wait fails for other reason, e.g. due to FailOnStderr,
+// and -1 if process has not exited normally or has not been started.
+func (execution *Execution) SetPostFinish(
+	hook func(exitCode int, err error),
+) *Execution {
+	execution.postFinish = hook
+
+	return execution
+}
+
+func (execution *Execution) runPostFinish(err error) {
+	if execution.postFinish == nil {
+		return
+	}
+
+	execution.postFinish(execution.getExitCode(err), err)
+}
+
+func (execution *Execution) getExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case IsExitStatus(err):
+		return GetExitStatus(err)
+	}
+
+	if state := execution.ProcessState(); state != nil {
+		return state.ExitCode()
+	}
+
+	return -1
+}
@@ -0,0 +1,102 @@
+package lexec
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreStartHookCanVetoExecution(t *testing.T) {
+	errForbidden := errors.New(`forbidden`)
+
+	var launched bool
+
+	hook := func(argv []string, env []string, dir string) error {
+		if argv[0] == `rm` {
+			return errForbidden
+		}
+
+		return nil
+	}
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Launch {
+			launched = true
+		}
+	}
+
+	err := NewExec(logger, exec.Command(`rm`, `-rf`, `/nonexistent`)).
+		SetPreStart(hook).
+		Run()
+	assert.Equal(t, errForbidden, err)
+	assert.False(t, launched)
+
+	err = NewExec(logger, exec.Command(`true`)).SetPreStart(hook).Run()
+	assert.NoError(t, err)
+	assert.True(t, launched)
+}
+
+func TestPreStartHookReceivesExpandedArgs(t *testing.T) {
+	errForbidden := errors.New(`forbidden`)
+
+	hook := func(argv []string, env []string, dir string) error {
+		for _, arg := range argv {
+			if arg == `/forbidden` {
+				return errForbidden
+			}
+		}
+
+		return nil
+	}
+
+	err := NewExec(nil, exec.Command(`ls`, `$TARGET`)).
+		AddEnv(`TARGET`, `/forbidden`).
+		ExpandEnv().
+		SetPreStart(hook).
+		Run()
+	assert.Equal(t, errForbidden, err)
+}
+
+func TestPostFinishHookReceivesExitCode(t *testing.T) {
+	type call struct {
+		code int
+		err  error
+	}
+
+	var calls []call
+
+	hook := func(code int, err error) {
+		calls = append(calls, call{code, err})
+	}
+
+	err := NewExec(nil, exec.Command(`true`)).SetPostFinish(hook).Run()
+	assert.NoError(t, err)
+
+	err = NewExec(nil, exec.Command(`sh`, `-c`, `exit 3`)).
+		SetPostFinish(hook).
+		Run()
+	assert.Error(t, err)
+
+	assert.Equal(t, []call{{0, nil}, {3, err}}, calls)
+}
+
+func TestPostFinishHookReceivesExitCodeOfFailedExecution(t *testing.T) {
+	var codes []int
+
+	hook := func(code int, err error) {
+		codes = append(codes, code)
+	}
+
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `echo error >&2`)).
+		FailOnStderr().
+		SetPostFinish(hook).
+		Run()
+	assert.Error(t, err)
+	assert.Equal(t, []int{0}, codes)
+
+	code, _, err := NewExec(nil, exec.Command(`/nonexistent`)).RunFull()
+	assert.Error(t, err)
+	assert.Equal(t, -1, code)
+}
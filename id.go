@@ -0,0 +1,29 @@
+package lexec
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// ID returns short random identifier of the execution, which can be used to
+// correlate log lines of concurrently running commands.
+func (execution *Execution) ID() string {
+	return execution.id
+}
+
+// SetLogID enables passing of execution ID along with every logged event as
+// `id` field to the logger set via SetFieldsLogger, logged data itself is
+// not altered. LoggerfFields appends it to every line in form of `[id=...]`.
+func (execution *Execution) SetLogID(enabled bool) *Execution {
+	execution.logID = enabled
+
+	return execution
+}
+
+func newID() string {
+	id := make([]byte, 4)
+
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
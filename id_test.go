@@ -0,0 +1,66 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutionsHaveDistinctIDs(t *testing.T) {
+	first := NewExec(nil, exec.Command(`true`))
+	second := NewExec(nil, exec.Command(`true`))
+
+	assert.Len(t, first.ID(), 8)
+	assert.NotEqual(t, first.ID(), second.ID())
+}
+
+func TestLoggerfFieldsIncludesIDWhenEnabled(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	execution := NewExec(nil, exec.Command(`echo`, `1`)).
+		SetFieldsLogger(LoggerfFields(logger)).
+		SetLogID(true)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	id := execution.ID()
+
+	assert.Equal(t, []string{
+		`launch | echo 1 [id=` + id + `]`,
+		`stdout |  1 [id=` + id + `]`,
+		`finish | echo 1 -> exit 0 [id=` + id + `]`,
+	}, log)
+}
+
+func TestSetLogIDDoesNotAlterLoggedData(t *testing.T) {
+	var logged []string
+
+	execution := NewExec(nil, exec.Command(`echo`, `1`)).
+		SetLogID(true)
+
+	execution.SetFieldsLogger(func(
+		command []string,
+		stream Stream,
+		data []byte,
+		fields []LogField,
+	) {
+		logged = append(logged, string(data))
+
+		assert.Equal(
+			t,
+			[]LogField{{Key: `id`, Value: execution.ID()}},
+			fields,
+		)
+	})
+
+	err := execution.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`launch`, `1`, `exit 0`}, logged)
+}
@@ -0,0 +1,87 @@
+package lexec
+
+import (
+	"time"
+)
+
+// SetIdleTimeout sets maximum duration command can run without producing
+// any output on stdout or stderr. If timeout is exceeded, command is killed
+// and Wait returns error, which mentions idle timeout.
+//
+// Only command process itself is killed, so Wait can still be blocked by
+// its children which hold stdout or stderr open.
+func (execution *Execution) SetIdleTimeout(timeout time.Duration) *Execution {
+	execution.mustNotBeStarted(`SetIdleTimeout`)
+
+	execution.idleTimeout = timeout
+
+	return execution
+}
+
+func (execution *Execution) startIdleTimer() {
+	if execution.idleTimeout <= 0 {
+		return
+	}
+
+	execution.timeoutMutex.Lock()
+	defer execution.timeoutMutex.Unlock()
+
+	execution.idleTimer = execution.clock.AfterFunc(
+		execution.idleTimeout,
+		execution.handleIdleTimeout,
+	)
+}
+
+func (execution *Execution) resetIdleTimer() {
+	execution.timeoutMutex.Lock()
+	defer execution.timeoutMutex.Unlock()
+
+	if execution.idleTimer == nil {
+		return
+	}
+
+	if !execution.idleTimer.Stop() {
+		// timer already fired
+		return
+	}
+
+	execution.idleTimer = execution.clock.AfterFunc(
+		execution.idleTimeout,
+		execution.handleIdleTimeout,
+	)
+}
+
+func (execution *Execution) stopIdleTimer() {
+	execution.timeoutMutex.Lock()
+	defer execution.timeoutMutex.Unlock()
+
+	if execution.idleTimer != nil {
+		execution.idleTimer.Stop()
+		execution.idleTimer = nil
+	}
+}
+
+func (execution *Execution) handleIdleTimeout() {
+	execution.timeoutMutex.Lock()
+	if execution.idleTimer == nil {
+		execution.timeoutMutex.Unlock()
+
+		return
+	}
+
+	execution.timedOut = true
+	execution.timeoutMutex.Unlock()
+
+	execution.kill()
+}
+
+// idleWriter resets idle timer on every write.
+type idleWriter struct {
+	execution *Execution
+}
+
+func (writer idleWriter) Write(data []byte) (int, error) {
+	writer.execution.resetIdleTimer()
+
+	return len(data), nil
+}
@@ -0,0 +1,67 @@
+package lexec
+
+import (
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdleTimeoutKillsSilentCommand(t *testing.T) {
+	var metrics Metrics
+
+	clock := newFakeClock()
+
+	execution := NewExec(nil, exec.Command(`sleep`, `10`)).
+		SetIdleTimeout(time.Minute).
+		SetMetricsSink(func(value Metrics) {
+			metrics = value
+		})
+
+	execution.clock = clock
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	<-clock.scheduled
+	clock.Advance(time.Minute)
+
+	err = execution.Wait()
+	assert.True(t, IsExitStatus(err))
+	assert.Contains(t, err.Error(), `idle timeout`)
+	assert.True(t, metrics.TimedOut)
+}
+
+func TestIdleTimeoutIsResetByOutput(t *testing.T) {
+	clock := newFakeClock()
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `while read line; do echo $line; done`),
+	).SetIdleTimeout(time.Minute)
+
+	execution.clock = clock
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	<-clock.scheduled
+
+	for _, line := range []string{"1\n", "2\n"} {
+		clock.Advance(45 * time.Second)
+
+		_, err = io.WriteString(execution.GetStdin(), line)
+		assert.NoError(t, err)
+
+		// timer is rescheduled on output
+		<-clock.scheduled
+	}
+
+	clock.Advance(45 * time.Second)
+
+	assert.NoError(t, execution.GetStdin().Close())
+	assert.NoError(t, execution.Wait())
+	assert.False(t, execution.WasTimedOut())
+}
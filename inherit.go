@@ -0,0 +1,68 @@
+package lexec
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// InheritStdout makes command write stdout directly into given file, so
+// command inherits its file descriptor and no copying is done, e.g. to write
+// into log file of the parent process.
+//
+// Stdout is neither captured nor logged in this mode, which is reported by
+// `stdout` field of launch event passed to the logger set via
+// SetFieldsLogger, e.g. LoggerfFields appends `[stdout="inherited from ..."]`
+// to the launch line. File is not closed by execution.
+func (execution *Execution) InheritStdout(file *os.File) *Execution {
+	execution.mustNotBeStarted(`InheritStdout`)
+
+	execution.stdoutInherit = file
+
+	return execution
+}
+
+// InheritStderr is same as InheritStdout, but for stderr.
+func (execution *Execution) InheritStderr(file *os.File) *Execution {
+	execution.mustNotBeStarted(`InheritStderr`)
+
+	execution.stderrInherit = file
+
+	return execution
+}
+
+func (execution *Execution) setupInherited(stream Stream, file *os.File) {
+	switch stream {
+	case Stdout:
+		execution.command.SetStdout(file)
+	case Stderr:
+		execution.command.SetStderr(file)
+	}
+}
+
+// getInheritFields returns fields which warn that inherited streams are not
+// captured or logged, they are passed along with launch event.
+func (execution *Execution) getInheritFields() []LogField {
+	var fields []LogField
+
+	for stream, file := range map[Stream]*os.File{
+		Stdout: execution.stdoutInherit,
+		Stderr: execution.stderrInherit,
+	} {
+		if file != nil {
+			fields = append(fields, LogField{
+				Key: string(stream),
+				Value: fmt.Sprintf(
+					`inherited from %s, output is not captured or logged`,
+					file.Name(),
+				),
+			})
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Key < fields[j].Key
+	})
+
+	return fields
+}
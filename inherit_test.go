@@ -0,0 +1,49 @@
+package lexec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInheritStdoutWritesDirectlyIntoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `log`)
+
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+
+	defer file.Close()
+
+	logged := []string{}
+
+	logger := func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	stdout, stderr, err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo out; echo err >&2`),
+	).
+		SetFieldsLogger(LoggerfFields(logger)).
+		InheritStdout(file).
+		NoStdLog().
+		Output()
+	assert.NoError(t, err)
+	assert.Empty(t, stdout)
+	assert.Equal(t, "err\n", string(stderr))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "out\n", string(data))
+
+	assert.Equal(t, []string{
+		`launch | sh -c "echo out; echo err >&2" [stdout="inherited from ` +
+			path + `, output is not captured or logged"]`,
+		`finish | sh -c "echo out; echo err >&2" -> exit 0`,
+	}, logged)
+}
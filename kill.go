@@ -0,0 +1,73 @@
+package lexec
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// Kill kills started command process immediately. Wait will return error
+// describing that command has been killed and WasKilled will return true.
+//
+// Error is returned if command is not started yet or it is not a local
+// process, e.g. remote command passed to New.
+func (execution *Execution) Kill() error {
+	if !execution.started {
+		return karma.Format(
+			nil,
+			`can't kill not started command: %s`,
+			execution.String(),
+		)
+	}
+
+	process := execution.Process()
+	if process == nil {
+		return karma.Format(
+			nil,
+			`can't kill non-local command: %s`,
+			execution.String(),
+		)
+	}
+
+	execution.timeoutMutex.Lock()
+	execution.killed = true
+	execution.timeoutMutex.Unlock()
+
+	err := process.Kill()
+	if err != nil {
+		return karma.Format(
+			err,
+			`can't kill command: %s`,
+			execution.String(),
+		)
+	}
+
+	return nil
+}
+
+// WasTimedOut returns true if command has been killed because idle timeout
+// set via SetIdleTimeout or deadline of RunWithDeadlinePartial has been
+// exceeded.
+func (execution *Execution) WasTimedOut() bool {
+	execution.timeoutMutex.Lock()
+	defer execution.timeoutMutex.Unlock()
+
+	return execution.timedOut || execution.deadlineExceeded
+}
+
+// WasKilled returns true if command has been killed by execution itself for
+// reason other than timeout: via Kill, because context passed to StdoutLines
+// has been done or because OnStdoutLineCancel callback returned false.
+//
+// Commands killed by external signal are reported by neither WasKilled nor
+// WasTimedOut.
+func (execution *Execution) WasKilled() bool {
+	execution.timeoutMutex.Lock()
+	defer execution.timeoutMutex.Unlock()
+
+	return execution.killed
+}
+
+func (execution *Execution) kill() {
+	if process := execution.Process(); process != nil {
+		_ = process.Kill()
+	}
+}
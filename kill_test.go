@@ -0,0 +1,74 @@
+package lexec
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWasTimedOutReportsIdleTimeout(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sleep`, `10`)).
+		SetIdleTimeout(100 * time.Millisecond)
+
+	err := execution.Run()
+	assert.True(t, IsExitStatus(err))
+	assert.True(t, execution.WasTimedOut())
+	assert.False(t, execution.WasKilled())
+}
+
+func TestWasKilledReportsKillOnContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	execution := NewExec(nil, exec.Command(`sleep`, `10`))
+
+	_, errs := execution.StdoutLines(ctx)
+
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-errs)
+	assert.True(t, execution.WasKilled())
+	assert.False(t, execution.WasTimedOut())
+}
+
+func TestWasKilledIgnoresExternalSignals(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sleep`, `10`))
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	err = execution.Process().Kill()
+	assert.NoError(t, err)
+
+	err = execution.Wait()
+	assert.Error(t, err)
+	assert.False(t, execution.WasKilled())
+	assert.False(t, execution.WasTimedOut())
+}
+
+func TestKillTerminatesStartedCommand(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sleep`, `10`))
+
+	startedAt := time.Now()
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	err = execution.Kill()
+	assert.NoError(t, err)
+
+	err = execution.Wait()
+	assert.True(t, IsExitStatus(err))
+	assert.Contains(t, err.Error(), `killed`)
+	assert.True(t, execution.WasKilled())
+	assert.False(t, execution.WasTimedOut())
+	assert.Less(t, time.Since(startedAt), 5*time.Second)
+}
+
+func TestKillReturnsErrorIfNotStarted(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sleep`, `10`)).Kill()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `not started`)
+}
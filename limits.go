@@ -0,0 +1,100 @@
+package lexec
+
+import (
+	"bytes"
+	"os/exec"
+	"syscall"
+
+	"github.com/reconquest/karma-go"
+)
+
+// SetMemoryLimit sets maximum size of command virtual memory in bytes.
+//
+// Limit is applied as RLIMIT_AS of the command process via prlimit right
+// after command is started, so runaway command will fail to allocate memory
+// instead of exhausting host memory. Memory allocated by the command before
+// limit is applied is not accounted. If command fails in a way typical for
+// failed allocation, Wait returns error which reports that memory limit is
+// exceeded. Supported only on Linux and only for commands created via
+// NewExec.
+func (execution *Execution) SetMemoryLimit(bytes uint64) *Execution {
+	execution.mustNotBeStarted(`SetMemoryLimit`)
+
+	execution.memoryLimit = bytes
+
+	return execution
+}
+
+func (execution *Execution) checkLimits() error {
+	if execution.memoryLimit == 0 {
+		return nil
+	}
+
+	if !memoryLimitSupported {
+		return karma.Format(
+			nil,
+			`memory limit is not supported on this platform: %s`,
+			execution.String(),
+		)
+	}
+
+	if _, ok := execution.command.(*command); !ok {
+		return karma.Format(
+			nil,
+			`memory limit can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	return nil
+}
+
+// applyLimits applies limits to the started command. Command is killed if
+// limits can't be applied.
+func (execution *Execution) applyLimits() error {
+	if execution.memoryLimit == 0 {
+		return nil
+	}
+
+	cmd := execution.command.(*command)
+
+	err := setMemoryLimit(cmd.Process.Pid, execution.memoryLimit)
+	if err == nil || err == syscall.ESRCH {
+		return nil
+	}
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	return karma.Describe("memory limit", execution.memoryLimit).Format(
+		err,
+		`can't set memory limit: %s`,
+		execution.String(),
+	)
+}
+
+// isMemoryLimitExceeded returns true if command with memory limit failed in
+// a way typical for failed allocation: crashed with SIGSEGV, SIGABRT or
+// SIGBUS or reported ENOMEM into stderr.
+func (execution *Execution) isMemoryLimitExceeded(
+	err error,
+	stderr []byte,
+) bool {
+	if execution.memoryLimit == 0 {
+		return false
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		signal, ok := getExitSignal(exitErr)
+		if ok && (signal == syscall.SIGSEGV ||
+			signal == syscall.SIGABRT ||
+			signal == syscall.SIGBUS) {
+			return true
+		}
+	}
+
+	stderr = bytes.ToLower(stderr)
+
+	return bytes.Contains(stderr, []byte(`cannot allocate memory`)) ||
+		bytes.Contains(stderr, []byte(`out of memory`))
+}
@@ -0,0 +1,25 @@
+package lexec
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const memoryLimitSupported = true
+
+func setMemoryLimit(pid int, bytes uint64) error {
+	limit := syscall.Rlimit{Cur: bytes, Max: bytes}
+
+	_, _, errno := syscall.RawSyscall6(
+		syscall.SYS_PRLIMIT64,
+		uintptr(pid),
+		syscall.RLIMIT_AS,
+		uintptr(unsafe.Pointer(&limit)),
+		0, 0, 0,
+	)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package lexec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLimitAllowsCommandFittingIntoIt(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sh`, `-c`, `echo ok`)).
+		SetMemoryLimit(64 * 1024 * 1024)
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", string(stdout))
+}
+
+func TestMemoryLimitFailsCommandAllocatingPastIt(t *testing.T) {
+	// limit is applied right after start, so allocation is delayed
+	execution := NewExec(nil, exec.Command(
+		`sh`, `-c`,
+		`sleep 0.1; x=$(head -c 134217728 /dev/zero | tr "\0" a); echo ${#x}`,
+	)).SetMemoryLimit(64 * 1024 * 1024)
+
+	err := execution.Run()
+	assert.Error(t, err)
+	assert.True(t, IsExitStatus(err))
+	assert.Contains(t, err.Error(), `exceeded memory limit`)
+	assert.Contains(t, err.Error(), `memory limit: 67108864`)
+}
+
+func TestMemoryLimitIsNotReportedForOtherFailures(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `exit 3`)).
+		SetMemoryLimit(64 * 1024 * 1024).
+		Run()
+	assert.True(t, IsExitStatus(err))
+	assert.NotContains(t, err.Error(), `memory limit`)
+}
+
+func TestMemoryLimitIsAppliedToCommandProcess(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sleep`, `10`)).
+		SetMemoryLimit(64 * 1024 * 1024)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	limits, err := ioutil.ReadFile(
+		fmt.Sprintf(`/proc/%d/limits`, execution.Process().Pid),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, execution.Kill())
+	_ = execution.Wait()
+
+	for _, line := range strings.Split(string(limits), "\n") {
+		if strings.HasPrefix(line, `Max address space`) {
+			assert.Equal(
+				t,
+				[]string{`67108864`, `67108864`, `bytes`},
+				strings.Fields(strings.TrimPrefix(line, `Max address space`)),
+			)
+
+			return
+		}
+	}
+
+	t.Fatalf(`no address space limit in /proc limits: %s`, limits)
+}
+
+func TestMemoryLimitKeepsArgv(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sh`, `-c`, `echo $0`)).
+		SetMemoryLimit(64 * 1024 * 1024)
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "sh\n", string(stdout))
+}
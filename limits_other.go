@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package lexec
+
+import (
+	"syscall"
+)
+
+const memoryLimitSupported = false
+
+func setMemoryLimit(pid int, bytes uint64) error {
+	return syscall.EINVAL
+}
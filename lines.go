@@ -0,0 +1,187 @@
+package lexec
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/reconquest/karma-go"
+)
+
+type lineListener struct {
+	stream Stream
+	match  func(string) bool
+	found  chan struct{}
+}
+
+// WaitForLine blocks until line matching given function appears in specified
+// stream of started command or until context is done.
+//
+// Lines that have been already written by the command before the call are
+// matched as well, so it is safe to call WaitForLine right after Start.
+//
+// Command is waited in background, so if it exits without writing matching
+// line, including last line without trailing newline, error is returned
+// without waiting for context. Wait still can be called afterwards and
+// returns same result.
+func (execution *Execution) WaitForLine(
+	ctx context.Context,
+	stream Stream,
+	match func(string) bool,
+) error {
+	if !execution.started {
+		return ErrNotStarted
+	}
+
+	listener := &lineListener{
+		stream: stream,
+		match:  match,
+		found:  make(chan struct{}),
+	}
+
+	execution.lineListenersMutex.Lock()
+
+	if execution.hasLine(stream, match) {
+		execution.lineListenersMutex.Unlock()
+
+		return nil
+	}
+
+	execution.lineListeners = append(execution.lineListeners, listener)
+	execution.lineListenersMutex.Unlock()
+
+	go func() {
+		_ = execution.Wait()
+	}()
+
+	select {
+	case <-listener.found:
+		return nil
+
+	case <-execution.waitDone:
+		// output is flushed before Wait is finished, so all lines including
+		// last unterminated one have been already passed to listeners
+		execution.removeLineListener(listener)
+
+		select {
+		case <-listener.found:
+			return nil
+		default:
+		}
+
+		return karma.Describe("stream", stream).Format(
+			execution.waitErr,
+			`command exited without matching line in output: %s`,
+			execution.String(),
+		)
+
+	case <-ctx.Done():
+		execution.removeLineListener(listener)
+
+		return karma.Describe("stream", stream).Format(
+			ctx.Err(),
+			`no matching line appeared in command output: %s`,
+			execution.String(),
+		)
+	}
+}
+
+func (execution *Execution) hasLine(
+	stream Stream,
+	match func(string) bool,
+) bool {
+	var output []byte
+
+	execution.combinedMutex.Lock()
+	for _, data := range execution.combinedStreams {
+		if data.Stream == stream {
+			output = append(output, data.Data...)
+		}
+	}
+	execution.combinedMutex.Unlock()
+
+	lines := bytes.Split(output, []byte("\n"))
+
+	// last item is either empty or not yet terminated line, which is
+	// complete only if command is finished
+	select {
+	case <-execution.waitDone:
+		if len(lines[len(lines)-1]) == 0 {
+			lines = lines[:len(lines)-1]
+		}
+	default:
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		if match(string(line)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OnStdoutLineCancel sets callback which is called for every stdout line of
+// the command. If callback returns false, command is killed, e.g. to stop on
+// first fatal error line, and callback is not called anymore. Wait returns
+// error of killed command and WasKilled returns true.
+//
+// Callback is called under log lock, so it should not block.
+func (execution *Execution) OnStdoutLineCancel(
+	callback func(line string) bool,
+) *Execution {
+	execution.stdoutLineCancel = callback
+
+	return execution
+}
+
+// checkStdoutLine should be called with logMutex held.
+func (execution *Execution) checkStdoutLine(line string) {
+	if execution.stdoutLineCancel == nil || execution.stdoutLineCanceled {
+		return
+	}
+
+	if !execution.stdoutLineCancel(line) {
+		execution.stdoutLineCanceled = true
+
+		// Kill can't be used, since output can arrive before Start returns
+		execution.timeoutMutex.Lock()
+		execution.killed = true
+		execution.timeoutMutex.Unlock()
+
+		execution.kill()
+	}
+}
+
+func (execution *Execution) notifyLine(stream Stream, line string) {
+	execution.lineListenersMutex.Lock()
+	defer execution.lineListenersMutex.Unlock()
+
+	listeners := execution.lineListeners[:0]
+	for _, listener := range execution.lineListeners {
+		if listener.stream == stream && listener.match(line) {
+			close(listener.found)
+			continue
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	execution.lineListeners = listeners
+}
+
+func (execution *Execution) removeLineListener(listener *lineListener) {
+	execution.lineListenersMutex.Lock()
+	defer execution.lineListenersMutex.Unlock()
+
+	for i, item := range execution.lineListeners {
+		if item == listener {
+			execution.lineListeners = append(
+				execution.lineListeners[:i],
+				execution.lineListeners[i+1:]...,
+			)
+
+			return
+		}
+	}
+}
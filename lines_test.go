@@ -0,0 +1,147 @@
+package lexec
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForLineBlocksUntilLineAppears(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo starting; sleep 0.2; echo ready`),
+	)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	started := time.Now()
+
+	err = execution.WaitForLine(ctx, Stdout, func(line string) bool {
+		return line == `ready`
+	})
+	assert.NoError(t, err)
+	assert.True(t, time.Since(started) >= 150*time.Millisecond)
+
+	assert.NoError(t, execution.Wait())
+}
+
+func TestWaitForLineMatchesAlreadyWrittenLine(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo ready >&2; sleep 0.2`),
+	)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = execution.WaitForLine(ctx, Stderr, func(line string) bool {
+		return line == `ready`
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, execution.Wait())
+}
+
+func TestWaitForLineReturnsErrorWhenContextIsDone(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo ready; sleep 0.3`),
+	)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		100*time.Millisecond,
+	)
+	defer cancel()
+
+	err = execution.WaitForLine(ctx, Stderr, func(line string) bool {
+		return line == `ready`
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `context deadline exceeded`)
+
+	assert.NoError(t, execution.Wait())
+}
+
+func TestOnStdoutLineCancelKillsCommand(t *testing.T) {
+	var lines []string
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1; echo FATAL; exec sleep 10`),
+	).
+		OnStdoutLineCancel(func(line string) bool {
+			lines = append(lines, line)
+
+			return line != `FATAL`
+		})
+
+	started := time.Now()
+
+	err := execution.Run()
+	assert.True(t, IsExitStatus(err))
+	assert.True(t, execution.WasKilled())
+	assert.Equal(t, []string{`1`, `FATAL`}, lines)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
+
+func TestWaitForLineMatchesUnterminatedLastLine(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `sleep 0.1; printf ready`),
+	)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = execution.WaitForLine(ctx, Stdout, func(line string) bool {
+		return line == `ready`
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, execution.Wait())
+}
+
+func TestWaitForLineReturnsErrorWhenCommandExitsWithoutMatch(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo starting; exit 1`),
+	)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	started := time.Now()
+
+	err = execution.WaitForLine(ctx, Stdout, func(line string) bool {
+		return line == `ready`
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `exited without matching line`)
+	assert.True(t, time.Since(started) < 4*time.Second)
+
+	err = execution.Wait()
+	assert.True(t, IsExitStatus(err))
+	assert.Equal(t, 1, GetExitStatus(err))
+}
@@ -0,0 +1,108 @@
+package lexec
+
+// LogOverflowPolicy specifies what to do with log event when log buffer is
+// full.
+type LogOverflowPolicy int
+
+const (
+	// LogOverflowBlock blocks command output processing until logger
+	// catches up.
+	LogOverflowBlock LogOverflowPolicy = iota
+
+	// LogOverflowDrop silently drops events which don't fit into buffer.
+	LogOverflowDrop
+)
+
+type logEvent struct {
+	command []string
+	stream  Stream
+	data    []byte
+	fields  []LogField
+}
+
+// SetLogBuffer makes execution pass events to the logger from single
+// goroutine through buffer of given size, so slow logger does not slow down
+// output processing. Order of events is preserved. When buffer is full,
+// given policy is applied.
+//
+// All buffered events are passed to the logger before Wait returns, or before
+// Start returns for command set up via Detach. Note, that logger is called
+// without holding mutex set by SetLogMutex.
+func (execution *Execution) SetLogBuffer(
+	size int,
+	policy LogOverflowPolicy,
+) *Execution {
+	execution.mustNotBeStarted(`SetLogBuffer`)
+
+	execution.logBufferSize = size
+	execution.logOverflow = policy
+
+	return execution
+}
+
+func (execution *Execution) startLogBuffer() {
+	if execution.logBufferSize <= 0 || execution.logger == nil {
+		return
+	}
+
+	events := make(chan logEvent, execution.logBufferSize)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for event := range events {
+			execution.callLogger(
+				event.command,
+				event.stream,
+				event.data,
+				event.fields,
+			)
+		}
+	}()
+
+	execution.logEvents = events
+	execution.logEventsDone = done
+}
+
+// stopLogBuffer waits until all buffered events are passed to the logger.
+func (execution *Execution) stopLogBuffer() {
+	execution.logMutex.Lock()
+	events := execution.logEvents
+	execution.logEvents = nil
+	execution.logMutex.Unlock()
+
+	if events == nil {
+		return
+	}
+
+	close(events)
+
+	<-execution.logEventsDone
+}
+
+// enqueueLogEvent should be called with logMutex held.
+func (execution *Execution) enqueueLogEvent(
+	command []string,
+	stream Stream,
+	data []byte,
+	fields []LogField,
+) {
+	event := logEvent{
+		command: command,
+		stream:  stream,
+		data:    append([]byte{}, data...),
+		fields:  fields,
+	}
+
+	if execution.logOverflow == LogOverflowDrop {
+		select {
+		case execution.logEvents <- event:
+		default:
+		}
+
+		return
+	}
+
+	execution.logEvents <- event
+}
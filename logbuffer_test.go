@@ -0,0 +1,56 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBufferPreservesOrderWithSlowLogger(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		time.Sleep(10 * time.Millisecond)
+
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(Loggerf(logger), exec.Command(`seq`, `5`)).
+		SetLogBuffer(100, LogOverflowBlock).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, `launch | seq 5`, log[0])
+	assert.Equal(t, `finish | seq 5 -> exit 0`, log[len(log)-1])
+
+	// output can be logged in several blocks of lines
+	var output []string
+	for _, entry := range log[1 : len(log)-1] {
+		output = append(output, strings.TrimPrefix(entry, `stdout |  `))
+	}
+
+	assert.Equal(t, "1\n2\n3\n4\n5", strings.Join(output, "\n"))
+}
+
+func TestLogBufferDropsEventsWhenFull(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		time.Sleep(200 * time.Millisecond)
+
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(Loggerf(logger), exec.Command(`echo`, `1`)).
+		SetLogBuffer(1, LogOverflowDrop).
+		Run()
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, log)
+	assert.Less(t, len(log), 3)
+	assert.Equal(t, `launch | echo 1`, log[0])
+}
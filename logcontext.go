@@ -0,0 +1,114 @@
+package lexec
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LogField represents key/value pair which is passed to FieldsLogger along
+// with every logged event.
+type LogField struct {
+	Key   string
+	Value string
+}
+
+// FieldsLogger is same as Logger, but additionally receives fields set via
+// SetLogContext, trace ID set via SetTraceContext and execution ID enabled
+// via SetLogID. Fields are passed separately, so logged data is never
+// altered. FieldsLogger is set via SetFieldsLogger.
+type FieldsLogger func(
+	command []string,
+	stream Stream,
+	data []byte,
+	fields []LogField,
+)
+
+// withFields returns FieldsLogger which ignores fields and passes events to
+// the Logger.
+func (logger Logger) withFields() FieldsLogger {
+	if logger == nil {
+		return nil
+	}
+
+	return func(command []string, stream Stream, data []byte, _ []LogField) {
+		logger(command, stream, data)
+	}
+}
+
+// SetFieldsLogger sets logger which receives fields set via SetLogContext,
+// SetTraceContext and SetLogID along with every event. It replaces logger
+// passed to New, which never receives fields. Use LoggerfFields or
+// LoggerSlogFields to log fields in format of Loggerf or LoggerSlog.
+func (execution *Execution) SetFieldsLogger(logger FieldsLogger) *Execution {
+	execution.logger = logger
+
+	if execution.noStreamLog && logger != nil {
+		execution.logger = fieldsLoggerNoOutput(logger)
+	}
+
+	return execution
+}
+
+// SetLogContext sets key/value pairs, e.g. host name or working directory,
+// which are attached to every logged event, so logs of commands running on
+// many hosts can be correlated.
+//
+// Pairs are sorted by key and passed as fields to the logger set via
+// SetFieldsLogger. LoggerfFields appends them to the end of the line in form
+// of `[key=value ...]` and LoggerSlogFields turns them into record
+// attributes. Logger passed to New does not receive them.
+func (execution *Execution) SetLogContext(
+	context map[string]string,
+) *Execution {
+	execution.logContext = nil
+
+	for key, value := range context {
+		execution.logContext = append(
+			execution.logContext,
+			LogField{Key: key, Value: value},
+		)
+	}
+
+	sort.Slice(execution.logContext, func(i, j int) bool {
+		return execution.logContext[i].Key < execution.logContext[j].Key
+	})
+
+	return execution
+}
+
+func (execution *Execution) getLogFields() []LogField {
+	var fields []LogField
+
+	if execution.logID {
+		fields = append(fields, LogField{Key: `id`, Value: execution.id})
+	}
+
+	if trace := execution.getTraceID(); trace != "" {
+		fields = append(fields, LogField{Key: `trace`, Value: trace})
+	}
+
+	if fields == nil {
+		return execution.logContext
+	}
+
+	return append(fields, execution.logContext...)
+}
+
+func formatLogFields(fields []LogField) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	items := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value := field.Value
+		if value == "" || strings.ContainsAny(value, " \t\n\"]\\") {
+			value = strconv.Quote(value)
+		}
+
+		items = append(items, field.Key+`=`+value)
+	}
+
+	return ` [` + strings.Join(items, ` `) + `]`
+}
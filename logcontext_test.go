@@ -0,0 +1,92 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLogContextAppendsContextToLoggerfFieldsLines(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(nil, exec.Command(`echo`, `1`)).
+		SetFieldsLogger(LoggerfFields(logger)).
+		SetLogContext(map[string]string{
+			"host": "web-1",
+			"dir":  "/srv/my app",
+		}).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | echo 1 [dir="/srv/my app" host=web-1]`,
+		`stdout |  1 [dir="/srv/my app" host=web-1]`,
+		`finish | echo 1 -> exit 0 [dir="/srv/my app" host=web-1]`,
+	}, log)
+}
+
+func TestSetLogContextDoesNotAlterLoggedData(t *testing.T) {
+	var logged []string
+
+	err := NewExec(nil, exec.Command(`echo`, `[ctx user=root] logged in`)).
+		SetFieldsLogger(func(
+			command []string,
+			stream Stream,
+			data []byte,
+			fields []LogField,
+		) {
+			if stream == Stdout {
+				logged = append(logged, string(data))
+
+				assert.Equal(
+					t,
+					[]LogField{{Key: `host`, Value: `web-1`}},
+					fields,
+				)
+			}
+		}).
+		SetLogContext(map[string]string{"host": "web-1"}).
+		Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`[ctx user=root] logged in`}, logged)
+}
+
+func TestLoggerfKeepsOutputResemblingLogContext(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(
+		Loggerf(logger),
+		exec.Command(`echo`, `[ctx user=root] logged in`),
+	).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, `stdout |  [ctx user=root] logged in`, log[1])
+}
+
+func TestSetLogContextIsNotPassedToLoggerPassedToNew(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(LoggerTee(Loggerf(logger)), exec.Command(`echo`, `1`)).
+		SetLogContext(map[string]string{"host": "web-1"}).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | echo 1`,
+		`stdout |  1`,
+		`finish | echo 1 -> exit 0`,
+	}, log)
+}
@@ -2,35 +2,180 @@ package lexec
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/acarl005/stripansi"
 	"github.com/reconquest/callbackwriter-go"
 	"github.com/reconquest/karma-go"
 	"github.com/reconquest/lineflushwriter-go"
 	"github.com/reconquest/nopio-go"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
 // Execution represents command prepared for the run.
+//
+// Methods which configure how command is started, like SetStdin, SetStdout,
+// SetEnvMap, SetUmask, SetIdleTimeout or LogStdin, panic if called after
+// Start, since they would silently have no effect. Methods which configure
+// logging of output and reporting of errors, like SetLogContext, NoLog or
+// SetErrorStream, can be called at any time and take effect for subsequent
+// events.
 type Execution struct {
+	id      string
 	command Command
 
 	stdin  io.ReadWriteCloser
 	stdout io.ReadWriter
 	stderr io.ReadWriter
 
-	combinedStreams []StreamData
-
-	logger Logger
+	stdinPipe   bool
+	logStdin    bool
+	stdinOSFile *os.File
+	stdinFd     uintptr
+	hasStdinFd  bool
+
+	stdoutInherit, stderrInherit *os.File
+
+	stdinFile, stdoutFile, stderrFile string
+
+	stdoutGzipFile, stderrGzipFile string
+
+	files          []*os.File
+	gzipWriters    []*gzip.Writer
+	extraFdWriters []*os.File
+	extraFdReaders []*os.File
+
+	combinedStreams  []StreamData
+	combinedMutex    *sync.Mutex
+	streamsRetained  int64
+	streamsTruncated bool
+	spillThreshold   int64
+	onStreamData     func(StreamData)
+	streamTransform  func(Stream, []byte) []byte
+	outputEncoding   encoding.Encoding
+
+	logger      FieldsLogger
+	noStreamLog bool
+	logID       bool
+	logMutex    *sync.Mutex
+
+	logLaunchAfterStart bool
+	lineNumbers         map[Stream]int
+	logIndent           string
+	maxLoggedLines      int
+	loggedLines         int
+	logTruncated        bool
+	maxLogLineBytes     int
+	logErrorHandler     func(error)
+
+	traceContext context.Context
+	traceIDFunc  func(context.Context) string
+	logContext   []LogField
+
+	logBufferSize int
+	logOverflow   LogOverflowPolicy
+	logEvents     chan logEvent
+	logEventsDone chan struct{}
 
 	closer func()
+
+	tees map[Stream][]*io.PipeWriter
+
+	started   bool
+	startedAt time.Time
+	waitOnce  sync.Once
+	waitDone  chan struct{}
+	waitErr   error
+	timedOut  bool
+	killed    bool
+	clock     clock
+
+	deadline         time.Duration
+	deadlineExceeded bool
+
+	idleTimeout  time.Duration
+	idleTimer    clockTimer
+	timeoutMutex sync.Mutex
+
+	heartbeat         func(time.Duration)
+	heartbeatInterval time.Duration
+	heartbeatTimer    clockTimer
+	heartbeatMutex    sync.Mutex
+
+	allowMultiline bool
+
+	normalizeNewlines    bool
+	keepEmptyWrites      bool
+	singleWriterOrdering bool
+	outputRateLimit      int
+	noOutputInError      bool
+	errorStream          Stream
+	sortErrorOutput      bool
+	capture              bool
+	exitCodeMapper       func(code int) error
+	allowedSignals       []syscall.Signal
+	stringFormat         func(args []string) string
+	argMask              func(index int, arg string) string
+
+	expandEnv    bool
+	envMap       map[string]string
+	envOverrides []string
+	envRedact    func(key, value string) string
+
+	preStart   func(argv []string, env []string, dir string) error
+	postFinish func(exitCode int, err error)
+
+	recorder      Recorder
+	recordMutex   *sync.Mutex
+	recordedStdin []byte
+
+	metricsSink func(Metrics)
+
+	failOnStderr     bool
+	stderrIgnores    []*regexp.Regexp
+	stderrFailures   []string
+	logIgnoredStderr bool
+
+	lineListeners      []*lineListener
+	lineListenersMutex sync.Mutex
+
+	stdoutLineCancel   func(string) bool
+	stdoutLineCanceled bool
+
+	memoryLimit uint64
+
+	detach bool
+
+	setsid     bool
+	setpgid    bool
+	foreground bool
+
+	path string
+
+	umask    int
+	hasUmask bool
+
+	chroot string
+
+	stdinWriteTimeout time.Duration
+	stdinContext      context.Context
+	stdinFunc         func(io.Writer) error
+	stdinCopy         func() error
+	stdinCopyDone     chan error
 }
 
 type Command interface {
@@ -87,35 +232,84 @@ type Logger func(command []string, stream Stream, data []byte)
 // Loggerf will turn typical Somethingf() logger function into acceptible
 // Logger function.
 func Loggerf(logger func(string, ...interface{})) Logger {
+	fieldsLogger := LoggerfFields(logger)
+
 	return func(command []string, stream Stream, data []byte) {
+		fieldsLogger(command, stream, data, nil)
+	}
+}
+
+// LoggerfFields is same as Loggerf, but returns FieldsLogger which can be set
+// via SetFieldsLogger and appends fields to the end of every line in form of
+// `[key=value ...]`.
+func LoggerfFields(logger func(string, ...interface{})) FieldsLogger {
+	return func(
+		command []string,
+		stream Stream,
+		data []byte,
+		fields []LogField,
+	) {
+		suffix := formatLogFields(fields)
+
 		switch stream {
 		case Launch:
 			logger(
-				`%-6s | %s`,
-				stream, FormatShellCommand(command),
+				`%-6s | %s%s`,
+				stream, FormatShellCommand(command), suffix,
 			)
 		case Finish:
 			logger(
-				`%-6s | %s -> %s`,
-				stream, FormatShellCommand(command), data,
+				`%-6s | %s -> %s%s`,
+				stream, FormatShellCommand(command), data, suffix,
 			)
 		default:
 			logger(
-				`%-6s |  %s`,
-				stream, string(data),
+				`%-6s |  %s%s`,
+				stream, string(data), suffix,
 			)
 		}
 	}
 }
 
+// LoggerNoOutput returns Logger that passes all events except stdout and
+// stderr output to the given logger.
 func LoggerNoOutput(logger Logger) Logger {
 	return func(command []string, stream Stream, data []byte) {
-		if stream == Launch || stream == Finish {
+		if isNoOutputStream(stream) {
 			logger(command, stream, data)
 		}
 	}
 }
 
+func fieldsLoggerNoOutput(logger FieldsLogger) FieldsLogger {
+	return func(
+		command []string,
+		stream Stream,
+		data []byte,
+		fields []LogField,
+	) {
+		if isNoOutputStream(stream) {
+			logger(command, stream, data, fields)
+		}
+	}
+}
+
+func isNoOutputStream(stream Stream) bool {
+	return stream != Stdout && stream != Stderr && stream != IgnoredStderr
+}
+
+// LoggerTee returns Logger that passes every event to all given loggers in
+// specified order.
+func LoggerTee(loggers ...Logger) Logger {
+	return func(command []string, stream Stream, data []byte) {
+		for _, logger := range loggers {
+			if logger != nil {
+				logger(command, stream, data)
+			}
+		}
+	}
+}
+
 // NewExec creates new execution object, that is used to start command and
 // setupStreams stdout/stderr/stdin streams.
 //
@@ -127,20 +321,29 @@ func NewExec(logger Logger, cmd *exec.Cmd) *Execution {
 }
 
 // New same as NewExec but second argument must implement interface Command.
+//
+// If logger is nil, nothing is logged unless LEXEC_DEBUG environment variable
+// is set, see DebugEnv.
 func New(logger Logger, cmd Command) *Execution {
-	if logger == nil {
-		logger = Loggerf(func(string, ...interface{}) {})
-	}
-
 	execution := &Execution{
+		id:      newID(),
 		command: cmd,
-		logger:  logger,
+		logger:  logger.withFields(),
+		clock:   realClock{},
+
+		waitDone: make(chan struct{}),
+	}
+
+	if logger == nil {
+		execution.logger = getDefaultLogger()
 	}
 
 	execution.stdout = &bytes.Buffer{}
 	execution.stderr = &bytes.Buffer{}
 
 	execution.combinedStreams = []StreamData{}
+	execution.combinedMutex = &sync.Mutex{}
+	execution.logMutex = &sync.Mutex{}
 
 	return execution
 }
@@ -149,12 +352,9 @@ func New(logger Logger, cmd Command) *Execution {
 //
 // If not called, internal buffer will be used.
 func (execution *Execution) SetStdout(target io.Writer) *Execution {
-	execution.stdout = struct {
-		io.Reader
-		io.Writer
-	}{
-		Writer: target,
-	}
+	execution.mustNotBeStarted(`SetStdout`)
+
+	execution.stdout = customWriter{Writer: target}
 
 	return execution
 }
@@ -163,12 +363,9 @@ func (execution *Execution) SetStdout(target io.Writer) *Execution {
 //
 // If not called, internal buffer will be used.
 func (execution *Execution) SetStderr(target io.Writer) *Execution {
-	execution.stderr = struct {
-		io.Reader
-		io.Writer
-	}{
-		Writer: target,
-	}
+	execution.mustNotBeStarted(`SetStderr`)
+
+	execution.stderr = customWriter{Writer: target}
 
 	return execution
 }
@@ -212,6 +409,8 @@ func (execution *Execution) GetStdin() io.WriteCloser {
 
 // SetStdin sets reader which will be used as program stdin.
 func (execution *Execution) SetStdin(source io.Reader) *Execution {
+	execution.mustNotBeStarted(`SetStdin`)
+
 	execution.stdin = struct {
 		io.WriteCloser
 		io.Reader
@@ -222,100 +421,343 @@ func (execution *Execution) SetStdin(source io.Reader) *Execution {
 	return execution
 }
 
+// mustNotBeStarted panics with clear message if command has been already
+// started, so misconfiguration is not silently ignored.
+func (execution *Execution) mustNotBeStarted(method string) {
+	if execution.started {
+		panic(fmt.Sprintf(
+			`lexec: %s called after Start: %s`,
+			method,
+			execution.String(),
+		))
+	}
+}
+
+// startCommand starts command with arguments expanded via ExpandEnv and with
+// umask set via SetUmask.
+func (execution *Execution) startCommand() error {
+	if args := execution.getExpandedArgs(); args != nil {
+		cmd := execution.command.(*command)
+
+		// output is logged with command args under log mutex, so expanded
+		// args are neither raced with nor exposed in log
+		execution.logMutex.Lock()
+		defer execution.logMutex.Unlock()
+
+		original := cmd.Args
+		defer func() {
+			cmd.Args = original
+		}()
+
+		cmd.Args = args
+	}
+
+	if execution.hasUmask {
+		return startWithUmask(execution.umask, execution.command.Start)
+	}
+
+	return execution.command.Start()
+}
+
 // Starts will start command, but will not wait for execution.
 func (execution *Execution) Start() error {
-	if execution.logger != nil {
-		execution.logger(
-			execution.command.GetArgs(),
-			Launch,
-			[]byte(`launch`),
-		)
+	execution.startLogBuffer()
+
+	err := execution.start()
+	if err != nil {
+		execution.closeExtraFds()
+		execution.stopLogBuffer()
+	} else if execution.detach {
+		// Wait is not required for detached command and its output is not
+		// logged, so buffer is not needed after start
+		execution.stopLogBuffer()
+	}
+
+	return err
+}
+
+func (execution *Execution) start() error {
+	err := execution.setupEnv()
+	if err != nil {
+		return err
+	}
+
+	err = execution.setupPath()
+	if err != nil {
+		return err
+	}
+
+	err = execution.runPreStart()
+	if err != nil {
+		return err
+	}
+
+	if !execution.logLaunchAfterStart {
+		execution.logLaunch()
+	}
+
+	err = execution.checkLimits()
+	if err != nil {
+		return err
 	}
 
-	err := execution.setupStreams()
+	err = execution.setupChroot()
 	if err != nil {
 		return err
 	}
 
-	if err := execution.command.Start(); err != nil {
+	err = execution.checkUmask()
+	if err != nil {
+		return err
+	}
+
+	err = execution.setupProcAttr()
+	if err != nil {
+		return err
+	}
+
+	err = execution.setupIO()
+	if err != nil {
+		return err
+	}
+
+	err = execution.startCommand()
+
+	execution.closeExtraFdWriters()
+
+	if execution.detach {
+		// command has own copies of files, so they are not needed anymore
+		_ = execution.closeFiles()
+	}
+
+	if err != nil {
 		return karma.Format(
-			err,
+			describeStartError(err),
 			`can't start command: %s`,
 			execution.String(),
 		)
 	}
 
+	err = execution.applyLimits()
+	if err != nil {
+		return err
+	}
+
+	if execution.logLaunchAfterStart {
+		execution.logLaunch()
+	}
+
+	execution.started = true
+	execution.startedAt = execution.clock.Now()
+
+	execution.startIdleTimer()
+	execution.startHeartbeat()
+	execution.startStdinCopy()
+
 	return nil
 }
 
+func (execution *Execution) setupIO() error {
+	if execution.detach {
+		return execution.setupDetach()
+	}
+
+	err := execution.setupFiles()
+	if err != nil {
+		return err
+	}
+
+	execution.setupCapture()
+
+	return execution.setupStreams()
+}
+
+func (execution *Execution) logLaunch() {
+	execution.logMutex.Lock()
+	execution.logWithFields(
+		Launch,
+		[]byte(`launch`),
+		execution.getInheritFields(),
+	)
+	execution.logMutex.Unlock()
+
+	execution.logEnv()
+}
+
 // Wait will wait for command to finish.
 // Wait can return ExitStatusError which can be checked using IsExitStatus(),
 // the exitcode can be obtained using GetExitStatus().
+//
+// If command has not been started, ErrNotStarted is returned. Wait can be
+// called several times, including concurrently, every call returns result of
+// the first one.
 func (execution *Execution) Wait() error {
+	if !execution.started {
+		return ErrNotStarted
+	}
+
+	execution.waitOnce.Do(func() {
+		err := execution.wait()
+		err = execution.record(err)
+
+		execution.stopLogBuffer()
+		execution.removeSpillFiles()
+
+		execution.runPostFinish(err)
+		execution.emitMetrics(err)
+
+		execution.waitErr = err
+		close(execution.waitDone)
+	})
+
+	return execution.waitErr
+}
+
+func (execution *Execution) wait() error {
 	err := execution.command.Wait()
+
+	execution.stopIdleTimer()
+	execution.stopHeartbeat()
+
+	stdinErr := execution.waitStdinCopy()
+
+	if execution.closer != nil {
+		execution.closer()
+	}
+
+	finish := `exit 0`
+
+	if signal, ok := execution.getAllowedSignal(err); ok {
+		err = nil
+		finish = fmt.Sprintf(`signal: %s`, signal)
+	}
+
 	if err != nil {
 		context := karma.Describe("command", execution.String())
 
-		var ok bool
+		var status int
+
+		switch exitErr := err.(type) {
+		case *exec.ExitError:
+			var ok bool
+
+			status, ok = getExitStatus(exitErr)
+			if !ok {
+				return context.Format(
+					err,
+					`unable to wait command execution`,
+				)
+			}
 
-		if _, ok = err.(*exec.ExitError); !ok {
+		case *replayExitError:
+			status = exitErr.code
+
+		default:
 			return context.Format(
 				err,
 				`unable to start command`,
 			)
 		}
 
-		var status syscall.WaitStatus
+		execution.log(
+			Finish,
+			[]byte(fmt.Sprintf(`exit %d`, status)),
+		)
 
-		if status, ok = err.(*exec.ExitError).Sys().(syscall.WaitStatus); !ok {
-			return context.Format(
-				err,
-				`unable to wait command execution`,
-			)
+		var (
+			output []string
+
+			stdout, stderr []byte
+		)
+
+		for _, data := range execution.combinedStreams {
+			if execution.errorStream == "" ||
+				execution.errorStream == data.Stream {
+				output = append(output, string(data.Data))
+			}
+
+			switch data.Stream {
+			case Stdout:
+				stdout = append(stdout, data.Data...)
+			case Stderr:
+				stderr = append(stderr, data.Data...)
+			}
 		}
 
-		if execution.logger != nil {
-			execution.logger(
-				execution.command.GetArgs(),
-				Finish,
-				[]byte(fmt.Sprintf(`exit %d`, status.ExitStatus())),
-			)
+		if execution.sortErrorOutput && execution.errorStream == "" {
+			output = nil
+
+			for _, data := range [][]byte{stdout, stderr} {
+				if len(data) > 0 {
+					output = append(output, string(data))
+				}
+			}
 		}
 
-		var output []string
+		message := "execution completed with non-zero exit code"
 
-		for _, data := range execution.combinedStreams {
-			output = append(output, string(data.Data))
+		if execution.isMemoryLimitExceeded(err, stderr) {
+			context = context.Describe("memory limit", execution.memoryLimit)
+			message = "execution failed due to exceeded memory limit"
 		}
 
-		if len(output) > 0 {
+		if len(output) > 0 && !execution.noOutputInError {
 			err = karma.Format(
 				strings.TrimSpace(stripansi.Strip(strings.Join(output, ""))),
 				err.Error(),
 			)
 		}
 
+		execution.timeoutMutex.Lock()
+		timedOut, deadlineExceeded :=
+			execution.timedOut, execution.deadlineExceeded
+		execution.timeoutMutex.Unlock()
+
+		if timedOut {
+			context = context.Describe("idle timeout", execution.idleTimeout)
+		}
+
+		if deadlineExceeded {
+			context = context.Describe("deadline", execution.deadline)
+		}
+
+		if execution.WasKilled() {
+			context = context.Describe("killed", true)
+		}
+
+		truncated := execution.WasOutputTruncated()
+		if truncated {
+			context = context.Describe("output truncated", true)
+		}
+
+		var mapped error
+		if execution.exitCodeMapper != nil {
+			mapped = execution.exitCodeMapper(status)
+			if mapped != nil {
+				message = mapped.Error()
+			}
+		}
+
 		return ExitStatusError{
 			Karma: context.
-				Describe("code", status.ExitStatus()).
-				Format(
-					err,
-					"execution completed with non-zero exit code",
-				),
-			ExitStatus: status.ExitStatus(),
+				Describe("code", status).
+				Format(err, "%s", message),
+			ExitStatus: status,
+			Stdout:     stdout,
+			Stderr:     stderr,
+			Truncated:  truncated,
+			mapped:     mapped,
 		}
 	}
 
-	if execution.closer != nil {
-		execution.closer()
+	execution.log(Finish, []byte(finish))
+
+	if stdinErr != nil {
+		return stdinErr
 	}
 
-	if execution.logger != nil {
-		execution.logger(
-			execution.command.GetArgs(),
-			Finish,
-			[]byte(`exit 0`),
-		)
+	if err := execution.getStderrError(); err != nil {
+		return err
 	}
 
 	return nil
@@ -364,12 +806,487 @@ func (execution *Execution) Output() ([]byte, []byte, error) {
 		}
 	}
 
+	_ = execution.closeSpillBuffers()
+
 	return stdout, stderr, err
 }
 
-// String returns string representation of command.
+// OutputCombined runs command and returns its stdout and stderr interleaved
+// in order of arrival, same as GetStreamsData, along with run error.
+func (execution *Execution) OutputCombined() ([]byte, error) {
+	err := execution.Run()
+
+	var output []byte
+
+	execution.combinedMutex.Lock()
+	for _, data := range execution.combinedStreams {
+		output = append(output, data.Data...)
+	}
+	execution.combinedMutex.Unlock()
+
+	return output, err
+}
+
+// RunFull runs command and returns its exit code, stdout and stderr
+// interleaved same as OutputCombined, and run error. Exit code is reported
+// even if run fails for other reason, e.g. due to FailOnStderr, and is -1 if
+// command has not been started or exit code can't be obtained.
+func (execution *Execution) RunFull() (int, []byte, error) {
+	combined, err := execution.OutputCombined()
+
+	return execution.getExitCode(err), combined, err
+}
+
+// RunJSON runs command and decodes its stdout as JSON into given value.
+func (execution *Execution) RunJSON(value interface{}) error {
+	stdout, stderr, err := execution.Output()
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(stdout, value)
+	if err != nil {
+		return karma.
+			Describe("stderr", strings.TrimSpace(string(stderr))).
+			Format(
+				err,
+				`can't decode command stdout as JSON: %s`,
+				execution.String(),
+			)
+	}
+
+	return nil
+}
+
+// RunKeyValues runs command and parses its stdout lines in form of
+// `key<sep>value`, e.g. `key=value` or `key: value`, into map. Whitespace
+// around keys and values is trimmed, empty lines are skipped, line without
+// separator is an error. Later values override earlier ones.
+func (execution *Execution) RunKeyValues(sep string) (map[string]string, error) {
+	stdout, _, err := execution.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		index := strings.Index(line, sep)
+		if index < 0 {
+			return nil, karma.
+				Describe("line", line).
+				Describe("separator", sep).
+				Format(
+					nil,
+					`can't parse command stdout as key/value pairs: %s`,
+					execution.String(),
+				)
+		}
+
+		key := strings.TrimSpace(line[:index])
+		values[key] = strings.TrimSpace(line[index+len(sep):])
+	}
+
+	return values, nil
+}
+
+// RunLine runs command and returns its stdout with trailing whitespace
+// trimmed.
+//
+// Error is returned if stdout contains more than one line, unless
+// SetAllowMultiline(true) has been called.
+func (execution *Execution) RunLine() (string, error) {
+	stdout, _, err := execution.Output()
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimRight(string(stdout), " \t\r\n")
+
+	if !execution.allowMultiline && strings.Contains(line, "\n") {
+		return "", karma.Describe("stdout", line).Format(
+			nil,
+			`command returned more than one line: %s`,
+			execution.String(),
+		)
+	}
+
+	return line, nil
+}
+
+// Stdout runs command and returns its stdout as is.
+func (execution *Execution) Stdout() (string, error) {
+	stdout, _, err := execution.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return string(stdout), nil
+}
+
+// MustStdout is same as Stdout, but panics if command fails.
+func (execution *Execution) MustStdout() string {
+	stdout, err := execution.Stdout()
+	if err != nil {
+		panic(err)
+	}
+
+	return stdout
+}
+
+// SetAllowMultiline allows RunLine to return output that contains multiple
+// lines.
+func (execution *Execution) SetAllowMultiline(allowed bool) *Execution {
+	execution.allowMultiline = allowed
+
+	return execution
+}
+
+// SetSkipEmptyWrites sets whether zero-length writes of command output are
+// skipped, so they don't produce empty StreamData items and empty log
+// events. Enabled by default.
+func (execution *Execution) SetSkipEmptyWrites(skip bool) *Execution {
+	execution.mustNotBeStarted(`SetSkipEmptyWrites`)
+
+	execution.keepEmptyWrites = !skip
+
+	return execution
+}
+
+// SetSingleWriterOrdering makes command write stdout and stderr into single
+// pipe read by single goroutine, so order of output in GetStreamsData matches
+// order of command writes.
+//
+// Since streams become indistinguishable, all output is captured, logged and
+// reported as Stdout stream, and GetStderr returns no data.
+func (execution *Execution) SetSingleWriterOrdering(enabled bool) *Execution {
+	execution.mustNotBeStarted(`SetSingleWriterOrdering`)
+
+	execution.singleWriterOrdering = enabled
+
+	return execution
+}
+
+// NormalizeNewlines enables conversion of CRLF line endings into LF in
+// captured and logged output. Command itself is not affected.
+func (execution *Execution) NormalizeNewlines(enabled bool) *Execution {
+	execution.mustNotBeStarted(`NormalizeNewlines`)
+
+	execution.normalizeNewlines = enabled
+
+	return execution
+}
+
+// String returns string representation of command, which is used in error
+// messages. By default argv is formatted using %q verb, it can be changed via
+// SetStringFormat.
 func (execution *Execution) String() string {
-	return fmt.Sprintf(`%q`, execution.command.GetArgs())
+	if execution.stringFormat != nil {
+		return execution.stringFormat(execution.getMaskedArgs())
+	}
+
+	return fmt.Sprintf(`%q`, execution.getMaskedArgs())
+}
+
+// SetArgMask sets function which is applied to every argument of command
+// before it is passed to the logger or used by String, so secrets can be
+// masked. Function receives index of argument in argv and should return
+// value to log. Command itself receives arguments as is.
+func (execution *Execution) SetArgMask(
+	mask func(index int, arg string) string,
+) *Execution {
+	execution.argMask = mask
+
+	return execution
+}
+
+func (execution *Execution) getMaskedArgs() []string {
+	args := execution.command.GetArgs()
+	if execution.argMask == nil {
+		return args
+	}
+
+	masked := make([]string, len(args))
+	for index, arg := range args {
+		masked[index] = execution.argMask(index, arg)
+	}
+
+	return masked
+}
+
+// SetStringFormat sets function which formats argv for String, e.g.
+// FormatShellCommand.
+func (execution *Execution) SetStringFormat(
+	format func(args []string) string,
+) *Execution {
+	execution.stringFormat = format
+
+	return execution
+}
+
+// NoOutputInError makes Wait omit command output from returned error.
+// Output is still available via GetStreamsData.
+func (execution *Execution) NoOutputInError() *Execution {
+	execution.noOutputInError = true
+
+	return execution
+}
+
+// SetLineNumbers enables prefixing of every logged output line with its
+// number, counted separately for every stream starting from 1, like `1: line`.
+func (execution *Execution) SetLineNumbers(enabled bool) *Execution {
+	if enabled {
+		execution.lineNumbers = map[Stream]int{}
+	} else {
+		execution.lineNumbers = nil
+	}
+
+	return execution
+}
+
+// numberLines should be called with logMutex held.
+func (execution *Execution) numberLines(stream Stream, lines []byte) []byte {
+	if execution.lineNumbers == nil {
+		return lines
+	}
+
+	var numbered []byte
+
+	for i, line := range bytes.Split(lines, []byte("\n")) {
+		if i > 0 {
+			numbered = append(numbered, '\n')
+		}
+
+		execution.lineNumbers[stream]++
+
+		numbered = append(
+			numbered,
+			strconv.Itoa(execution.lineNumbers[stream])+": "...,
+		)
+		numbered = append(numbered, line...)
+	}
+
+	return numbered
+}
+
+// SetMaxLoggedLines sets maximum number of stdout and stderr lines passed to
+// the logger. Once limit is reached, "(log truncated)" marker is logged and
+// further output is not logged, but it is still captured completely.
+func (execution *Execution) SetMaxLoggedLines(limit int) *Execution {
+	execution.maxLoggedLines = limit
+
+	return execution
+}
+
+// logOutput should be called with logMutex held.
+func (execution *Execution) logOutput(stream Stream, lines []byte) {
+	if execution.logTruncated {
+		return
+	}
+
+	if execution.maxLoggedLines > 0 {
+		split := bytes.Split(lines, []byte("\n"))
+
+		remaining := execution.maxLoggedLines - execution.loggedLines
+		if len(split) > remaining {
+			if remaining > 0 {
+				execution.logOutput(
+					stream,
+					bytes.Join(split[:remaining], []byte("\n")),
+				)
+			}
+
+			execution.logTruncated = true
+			execution.logUnlocked(stream, []byte(`(log truncated)`))
+
+			return
+		}
+
+		execution.loggedLines += len(split)
+	}
+
+	execution.logUnlocked(
+		stream,
+		execution.indentLines(execution.numberLines(stream, lines)),
+	)
+}
+
+// SetLogIndent sets string which every logged output line is prefixed with,
+// e.g. "  ", so output of subprocess can be visually nested under parent
+// operation in logs.
+func (execution *Execution) SetLogIndent(indent string) *Execution {
+	execution.logIndent = indent
+
+	return execution
+}
+
+func (execution *Execution) indentLines(lines []byte) []byte {
+	if execution.logIndent == "" {
+		return lines
+	}
+
+	indent := []byte(execution.logIndent)
+
+	indented := append([]byte{}, indent...)
+	indented = append(
+		indented,
+		bytes.ReplaceAll(lines, []byte("\n"), append([]byte("\n"), indent...))...,
+	)
+
+	return indented
+}
+
+// SetMaxLogLineBytes sets maximum length of output line passed to the
+// logger. Longer lines are passed to the logger in several parts, every part
+// except last one ends with "..." marker. Lines passed to WaitForLine
+// listeners are split same way.
+func (execution *Execution) SetMaxLogLineBytes(limit int) *Execution {
+	execution.mustNotBeStarted(`SetMaxLogLineBytes`)
+
+	execution.maxLogLineBytes = limit
+
+	return execution
+}
+
+// lineLimitWriter inserts line breaks into lines longer than limit, so they
+// are flushed by underlying line flushing writer in parts.
+type lineLimitWriter struct {
+	writer io.Writer
+	limit  int
+	length int
+}
+
+func (writer *lineLimitWriter) Write(data []byte) (int, error) {
+	size := len(data)
+
+	for len(data) > 0 {
+		free := writer.limit - writer.length
+		index := bytes.IndexByte(data, '\n')
+
+		switch {
+		case index >= 0 && index <= free:
+			_, err := writer.writer.Write(data[:index+1])
+			if err != nil {
+				return size - len(data), err
+			}
+
+			writer.length = 0
+			data = data[index+1:]
+
+		case len(data) <= free:
+			_, err := writer.writer.Write(data)
+			if err != nil {
+				return size - len(data), err
+			}
+
+			writer.length += len(data)
+			data = nil
+
+		default:
+			part := append(append([]byte{}, data[:free]...), "...\n"...)
+
+			_, err := writer.writer.Write(part)
+			if err != nil {
+				return size - len(data), err
+			}
+
+			writer.length = 0
+			data = data[free:]
+		}
+	}
+
+	return size, nil
+}
+
+// SetLogLaunchAfterStart makes Start log launch line only after process is
+// actually spawned, so failed starts are not logged as launched. By default
+// launch line is logged before process is spawned.
+//
+// Note, that in this mode output of very fast command can be logged before
+// launch line.
+func (execution *Execution) SetLogLaunchAfterStart(enabled bool) *Execution {
+	execution.mustNotBeStarted(`SetLogLaunchAfterStart`)
+
+	execution.logLaunchAfterStart = enabled
+
+	return execution
+}
+
+// SetErrorStream sets stream which output is included into error returned
+// by Wait when command exits with non-zero code, e.g. Stderr. By default
+// combined output of stdout and stderr is included.
+func (execution *Execution) SetErrorStream(stream Stream) *Execution {
+	execution.errorStream = stream
+
+	return execution
+}
+
+// SortErrorOutputByStream sets whether output included into error returned
+// by Wait is grouped by stream: whole stdout goes first, then whole stderr.
+// By default output is included in order of arrival, which depends on
+// scheduling, so grouping makes error messages reproducible.
+func (execution *Execution) SortErrorOutputByStream(enabled bool) *Execution {
+	execution.sortErrorOutput = enabled
+
+	return execution
+}
+
+// SetExitCodeMapper sets function which translates non-zero exit codes into
+// domain-specific errors. If mapper returns non-nil error, its message is
+// used instead of generic message of ExitStatusError returned by Wait, and
+// error itself can be matched via errors.Is or errors.As. Command output is
+// still included into the error.
+func (execution *Execution) SetExitCodeMapper(
+	mapper func(code int) error,
+) *Execution {
+	execution.exitCodeMapper = mapper
+
+	return execution
+}
+
+// SetLogErrorHandler sets function which is called when logger fails, either
+// by panicking or by failing to write logged output. Logger failures never
+// abort capturing of command output, they are ignored if handler is not set.
+func (execution *Execution) SetLogErrorHandler(
+	handler func(error),
+) *Execution {
+	execution.logErrorHandler = handler
+
+	return execution
+}
+
+func (execution *Execution) handleLogError(err error) {
+	if execution.logErrorHandler != nil {
+		execution.logErrorHandler(err)
+	}
+}
+
+// logErrorWriter passes write errors to the given handler instead of
+// returning them, so failing logger will not abort output capturing.
+type logErrorWriter struct {
+	writer  io.Writer
+	onError func(error)
+}
+
+func (writer logErrorWriter) Write(data []byte) (int, error) {
+	_, err := writer.writer.Write(data)
+	if err != nil {
+		writer.onError(err)
+	}
+
+	return len(data), nil
+}
+
+// SetLogMutex sets mutex which guards all logger calls, so several
+// executions sharing same mutex will not interleave their log writes.
+func (execution *Execution) SetLogMutex(mutex *sync.Mutex) *Execution {
+	execution.logMutex = mutex
+
+	return execution
 }
 
 func (execution *Execution) NoLog() *Execution {
@@ -379,16 +1296,98 @@ func (execution *Execution) NoLog() *Execution {
 }
 
 func (execution *Execution) NoStdLog() *Execution {
-	execution.logger = LoggerNoOutput(execution.logger)
+	if execution.logger != nil && !execution.noStreamLog {
+		execution.logger = fieldsLoggerNoOutput(execution.logger)
+		execution.noStreamLog = true
+	}
 
 	return execution
 }
 
+// IsLogging returns true if execution events will be passed to the logger,
+// so callers can skip formatting of expensive log messages otherwise. It
+// returns false for executions created with nil logger unless logging is
+// enabled via DebugEnv.
+func (execution *Execution) IsLogging() bool {
+	return execution.logger != nil
+}
+
+// IsStreamLogging returns true if stdout/stderr output will be passed to the
+// logger.
+func (execution *Execution) IsStreamLogging() bool {
+	return execution.logger != nil && !execution.noStreamLog
+}
+
+func (execution *Execution) log(stream Stream, data []byte) {
+	execution.logMutex.Lock()
+	defer execution.logMutex.Unlock()
+
+	execution.logUnlocked(stream, data)
+}
+
+// logUnlocked should be used when logMutex is already held, e.g. from
+// line flushing writers.
+func (execution *Execution) logUnlocked(stream Stream, data []byte) {
+	execution.logWithFields(stream, data, nil)
+}
+
+// logWithFields is same as logUnlocked, but passes given fields to the logger
+// in addition to fields of the execution.
+func (execution *Execution) logWithFields(
+	stream Stream,
+	data []byte,
+	extra []LogField,
+) {
+	if execution.logger == nil {
+		return
+	}
+
+	fields := execution.getLogFields()
+	if len(extra) > 0 {
+		fields = append(append([]LogField{}, fields...), extra...)
+	}
+
+	if execution.logEvents != nil {
+		execution.enqueueLogEvent(
+			execution.getMaskedArgs(),
+			stream,
+			data,
+			fields,
+		)
+
+		return
+	}
+
+	execution.callLogger(execution.getMaskedArgs(), stream, data, fields)
+}
+
+func (execution *Execution) callLogger(
+	command []string,
+	stream Stream,
+	data []byte,
+	fields []LogField,
+) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			execution.handleLogError(
+				karma.Format(recovered, `logger panicked on %s`, stream),
+			)
+		}
+	}()
+
+	execution.logger(command, stream, data, fields)
+}
+
 func (execution *Execution) setupStreams() error {
-	var (
-		streamMutex   = &sync.Mutex{}
-		combinedMutex = &sync.Mutex{}
-	)
+	outputMutex := &sync.Mutex{}
+
+	var limiter *rateLimiter
+	if execution.outputRateLimit > 0 {
+		limiter = &rateLimiter{
+			rate:  execution.outputRateLimit,
+			clock: execution.clock,
+		}
+	}
 
 	loggerize := func(
 		stream Stream,
@@ -398,45 +1397,138 @@ func (execution *Execution) setupStreams() error {
 			callbackwriter.New(
 				nopio.NopWriteCloser{},
 				func(data []byte) {
-					execution.logger(
-						execution.command.GetArgs(),
-						stream,
-						bytes.TrimRight(data, "\n"),
-					)
+					lines := bytes.TrimRight(data, "\n")
+
+					if stream == Stderr && execution.logIgnoredStderr {
+						execution.logStderr(lines)
+					} else {
+						execution.logOutput(stream, lines)
+					}
+
+					for _, line := range bytes.Split(lines, []byte("\n")) {
+						execution.notifyLine(stream, string(line))
+
+						switch stream {
+						case Stdout:
+							execution.checkStdoutLine(string(line))
+						case Stderr:
+							execution.checkStderrLine(string(line))
+						}
+					}
 				},
 				nil,
 			),
-			streamMutex,
+			execution.logMutex,
 			true,
 		)
 
-		return io.MultiWriter(
+		var loggerWriter io.Writer = logger
+		if execution.maxLogLineBytes > 0 {
+			loggerWriter = &lineLimitWriter{
+				writer: logger,
+				limit:  execution.maxLogLineBytes,
+			}
+		}
+
+		writers := []io.Writer{
 			newStreamWriter(
 				&execution.combinedStreams,
-				combinedMutex,
+				execution.combinedMutex,
 				stream,
+				execution.onStreamData,
+				&execution.streamsRetained,
+				execution.spillThreshold,
+				&execution.streamsTruncated,
 			),
-			output, logger,
-		), logger.Close
-	}
+			newLockedWriter(output, outputMutex),
+			logErrorWriter{
+				writer:  loggerWriter,
+				onError: execution.handleLogError,
+			},
+		}
 
-	if execution.logger != nil {
-		var (
-			stdout, stderr io.Writer
+		for _, tee := range execution.tees[stream] {
+			writers = append(writers, teeWriter{tee})
+		}
+
+		if execution.idleTimeout > 0 {
+			writers = append(writers, idleWriter{execution})
+		}
 
-			stdoutCloser, stderrCloser func() error
+		var (
+			writer = io.MultiWriter(writers...)
+			closer = logger.Close
 		)
 
-		if execution.stdout != nil {
-			stdout, stdoutCloser = loggerize(
-				Stdout,
-				execution.stdout,
+		if !execution.keepEmptyWrites {
+			writer = skipEmptyWriter{writer}
+		}
+
+		if execution.streamTransform != nil {
+			writer = &transformWriter{
+				writer:    writer,
+				stream:    stream,
+				transform: execution.streamTransform,
+			}
+		}
+
+		if limiter != nil {
+			writer = newRateLimitedWriter(writer, limiter)
+		}
+
+		if execution.normalizeNewlines {
+			normalizer := newNewlineNormalizer(writer)
+
+			writer = normalizer
+			closer = func() error {
+				_ = normalizer.Flush()
+
+				return logger.Close()
+			}
+		}
+
+		if execution.outputEncoding != nil {
+			decoder := transform.NewWriter(
+				writer,
+				execution.outputEncoding.NewDecoder(),
 			)
+			flush := closer
 
-			execution.command.SetStdout(stdout)
+			writer = decoder
+			closer = func() error {
+				_ = decoder.Close()
+
+				return flush()
+			}
 		}
 
-		if execution.stderr != nil {
+		return writer, closer
+	}
+
+	var (
+		stdout, stderr io.Writer
+
+		stdoutCloser, stderrCloser func() error
+	)
+
+	if execution.stdoutInherit != nil {
+		execution.setupInherited(Stdout, execution.stdoutInherit)
+	} else if execution.stdout != nil {
+		stdout, stdoutCloser = loggerize(
+			Stdout,
+			execution.stdout,
+		)
+
+		execution.command.SetStdout(stdout)
+	}
+
+	if execution.stderrInherit != nil {
+		execution.setupInherited(Stderr, execution.stderrInherit)
+	} else if execution.stderr != nil {
+		if execution.singleWriterOrdering && stdout != nil {
+			// same writer makes command use same pipe for both streams
+			execution.command.SetStderr(stdout)
+		} else {
 			stderr, stderrCloser = loggerize(
 				Stderr,
 				execution.stderr,
@@ -444,27 +1536,29 @@ func (execution *Execution) setupStreams() error {
 
 			execution.command.SetStderr(stderr)
 		}
+	}
 
-		execution.closer = func() {
-			if stdoutCloser != nil {
-				_ = stdoutCloser()
-			}
+	var once sync.Once
 
-			if stderrCloser != nil {
-				_ = stderrCloser()
-			}
-		}
-	} else {
-		if execution.stdout != nil {
-			execution.command.SetStdout(execution.stdout)
-		}
+	execution.closer = func() {
+		once.Do(func() {
+			execution.closeStreams(stdoutCloser, stderrCloser)
+		})
+	}
 
-		if execution.stderr != nil {
-			execution.command.SetStderr(execution.stderr)
-		}
+	err := execution.setupStdinFd()
+	if err != nil {
+		return err
 	}
 
-	if execution.stdin == nil {
+	if execution.stdinOSFile != nil {
+		execution.command.SetStdin(execution.stdinOSFile)
+	} else if execution.stdinFunc != nil {
+		err := execution.setupStdinFunc()
+		if err != nil {
+			return err
+		}
+	} else if execution.stdin == nil {
 		stdin, err := execution.command.StdinPipe()
 		if err != nil {
 			return karma.Format(
@@ -474,24 +1568,64 @@ func (execution *Execution) setupStreams() error {
 			)
 		}
 
+		if execution.watchStdin() {
+			stdin = &stdinLogWriter{
+				WriteCloser: stdin,
+				log:         execution.handleStdinChunk,
+			}
+		}
+
 		execution.stdin = struct {
 			io.WriteCloser
 			io.Reader
 		}{
 			WriteCloser: stdin,
 		}
+
+		execution.stdinPipe = true
+	} else if execution.stdinWriteTimeout > 0 ||
+		execution.stdinContext != nil {
+		err := execution.setupStdinCopy()
+		if err != nil {
+			return err
+		}
 	} else {
-		execution.command.SetStdin(execution.stdin)
+		execution.command.SetStdin(execution.getStdinSource())
 	}
 
 	return nil
 }
 
+func (execution *Execution) closeStreams(closers ...func() error) {
+	for _, closer := range closers {
+		if closer != nil {
+			_ = closer()
+		}
+	}
+
+	for _, tees := range execution.tees {
+		for _, tee := range tees {
+			_ = tee.Close()
+		}
+	}
+
+	_ = execution.closeFiles()
+}
+
+// Command returns command which has been passed to New. For executions
+// created via NewExec it wraps given exec.Cmd.
+func (execution *Execution) Command() Command {
+	return execution.command
+}
+
 func (execution *Execution) Process() *os.Process {
 	// this wrapper needs only in case when instead of exec.Command has been
 	// passed runcmd.Remote
-	if cmd, ok := execution.command.(*command); ok {
+	switch cmd := execution.command.(type) {
+	case *command:
 		return cmd.Process
+	case *attachedCommand:
+		return cmd.process
 	}
 
 	return nil
@@ -505,6 +1639,28 @@ func (execution *Execution) ProcessState() *os.ProcessState {
 	return nil
 }
 
+// UserTime returns user CPU time of the finished command or zero if command
+// is not finished yet.
+func (execution *Execution) UserTime() time.Duration {
+	state := execution.ProcessState()
+	if state == nil {
+		return 0
+	}
+
+	return state.UserTime()
+}
+
+// SystemTime returns system CPU time of the finished command or zero if
+// command is not finished yet.
+func (execution *Execution) SystemTime() time.Duration {
+	state := execution.ProcessState()
+	if state == nil {
+		return 0
+	}
+
+	return state.SystemTime()
+}
+
 func (execution *Execution) SysProcAttr() *syscall.SysProcAttr {
 	if cmd, ok := execution.command.(*command); ok {
 		return cmd.SysProcAttr
@@ -513,6 +1669,27 @@ func (execution *Execution) SysProcAttr() *syscall.SysProcAttr {
 	return nil
 }
 
+// GetStreamsData returns stdout and stderr output of the command in order it
+// has been received.
+//
+// By default each item holds exactly one chunk of data as it has been read
+// from the command output pipe, chunks are not merged or split by lines,
+// unlike output passed to the logger. Chunk boundaries match command writes
+// only when output is consumed faster than produced: OS can deliver several
+// writes as one chunk, and a write larger than pipe buffer can be delivered as
+// several chunks.
+//
+// Options which process output change chunks before they are stored:
+// SetOutputRateLimit splits chunks into pieces no larger than the rate,
+// NormalizeNewlines and SetOutputEncoding rewrite chunks and can hold
+// incomplete trailing bytes back until next chunk, SetStreamTransform replaces
+// or drops chunks and SpillToDiskAfter stops storing them past threshold.
+// Empty chunks are dropped unless disabled via SetSkipEmptyWrites.
+//
+// Order of chunks within one stream is always preserved, but stdout and
+// stderr are read by separate goroutines, so order between chunks of
+// different streams depends on scheduling, unless SetSingleWriterOrdering is
+// enabled.
 func (execution *Execution) GetStreamsData() []StreamData {
 	return execution.combinedStreams
 }
@@ -2,10 +2,16 @@ package lexec
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -84,6 +90,251 @@ func TestCanPassStdinToCommand(t *testing.T) {
 	)
 }
 
+func TestLoggerTeePassesEveryEventToEachLogger(t *testing.T) {
+	var first, second []string
+
+	collect := func(log *[]string) Logger {
+		return func(command []string, stream Stream, data []byte) {
+			*log = append(*log, fmt.Sprintf(`%s: %s`, stream, data))
+		}
+	}
+
+	execution := NewExec(
+		LoggerTee(collect(&first), nil, collect(&second)),
+		exec.Command(`echo`, `1`),
+	)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	expected := []string{
+		`launch: launch`,
+		`stdout: 1`,
+		`finish: exit 0`,
+	}
+
+	assert.Equal(t, expected, first)
+	assert.Equal(t, expected, second)
+}
+
+func TestReportsLoggingState(t *testing.T) {
+	t.Setenv(DebugEnv, ``)
+
+	execution := NewExec(nil, exec.Command(`true`))
+	assert.False(t, execution.IsLogging())
+	assert.False(t, execution.IsStreamLogging())
+
+	execution = NewExec(
+		func(command []string, stream Stream, data []byte) {},
+		exec.Command(`true`),
+	)
+	assert.True(t, execution.IsLogging())
+	assert.True(t, execution.IsStreamLogging())
+
+	execution.NoStdLog()
+	assert.True(t, execution.IsLogging())
+	assert.False(t, execution.IsStreamLogging())
+
+	execution.NoLog()
+	assert.False(t, execution.IsLogging())
+	assert.False(t, execution.IsStreamLogging())
+}
+
+func TestRunJSONDecodesStdout(t *testing.T) {
+	var result struct {
+		A int `json:"a"`
+	}
+
+	err := NewExec(nil, exec.Command(`echo`, `{"a":1}`)).RunJSON(&result)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.A)
+}
+
+func TestRunJSONReportsStderrOnInvalidOutput(t *testing.T) {
+	var result struct{}
+
+	err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo oops; echo deprecated flag >&2`),
+	).RunJSON(&result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `can't decode command stdout as JSON`)
+	assert.Contains(t, err.Error(), `stderr: deprecated flag`)
+}
+
+func TestRunKeyValuesParsesStdout(t *testing.T) {
+	values, err := NewExec(
+		nil,
+		exec.Command(`printf`, `a=1\nb = 2\n\nc=x=y\n`),
+	).RunKeyValues(`=`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{`a`: `1`, `b`: `2`, `c`: `x=y`}, values)
+
+	values, err = NewExec(
+		nil,
+		exec.Command(`printf`, `name: lexec\nversion: 1\n`),
+	).RunKeyValues(`:`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{`name`: `lexec`, `version`: `1`}, values)
+}
+
+func TestRunKeyValuesReportsLineWithoutSeparator(t *testing.T) {
+	_, err := NewExec(nil, exec.Command(`echo`, `oops`)).RunKeyValues(`=`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `line: oops`)
+}
+
+func TestWaitReturnsErrNotStartedBeforeStart(t *testing.T) {
+	err := NewExec(nil, exec.Command(`true`)).Wait()
+	assert.True(t, errors.Is(err, ErrNotStarted))
+}
+
+func TestRunLineReturnsTrimmedSingleLine(t *testing.T) {
+	line, err := NewExec(nil, exec.Command(`echo`, `abc `)).RunLine()
+	assert.NoError(t, err)
+	assert.Equal(t, `abc`, line)
+}
+
+func TestRunLineFailsOnMultipleLines(t *testing.T) {
+	_, err := NewExec(nil, exec.Command(`printf`, `a\nb\n`)).RunLine()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `more than one line`)
+
+	line, err := NewExec(nil, exec.Command(`printf`, `a\nb\n`)).
+		SetAllowMultiline(true).
+		RunLine()
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb", line)
+}
+
+func TestStdoutReturnsUntrimmedOutput(t *testing.T) {
+	stdout, err := NewExec(nil, exec.Command(`printf`, ` a\nb\n\n`)).Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, " a\nb\n\n", stdout)
+
+	assert.Equal(
+		t,
+		"a\n",
+		NewExec(nil, exec.Command(`echo`, `a`)).MustStdout(),
+	)
+}
+
+func TestMustStdoutPanicsOnFailure(t *testing.T) {
+	_, err := NewExec(nil, exec.Command(`false`)).Stdout()
+	assert.True(t, IsExitStatus(err))
+
+	assert.Panics(t, func() {
+		NewExec(nil, exec.Command(`false`)).MustStdout()
+	})
+}
+
+func TestOutputCombinedInterleavesStreams(t *testing.T) {
+	output, err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 1; echo 2 >&2; echo 3; echo 4 >&2; exit 1`,
+		),
+	).
+		SetSingleWriterOrdering(true).
+		OutputCombined()
+	assert.True(t, IsExitStatus(err))
+	assert.Equal(t, "1\n2\n3\n4\n", string(output))
+}
+
+func TestRunFullReturnsCodeOutputAndError(t *testing.T) {
+	code, output, err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 1; echo 2 >&2; echo 3; exit 4`,
+		),
+	).
+		SetSingleWriterOrdering(true).
+		RunFull()
+	assert.Equal(t, 4, code)
+	assert.Equal(t, "1\n2\n3\n", string(output))
+	assert.True(t, IsExitStatus(err))
+
+	code, output, err = NewExec(nil, exec.Command(`echo`, `ok`)).RunFull()
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "ok\n", string(output))
+	assert.NoError(t, err)
+}
+
+func TestReportsCPUTimesOfFinishedCommand(t *testing.T) {
+	execution := NewExec(nil, exec.Command(
+		`sh`, `-c`,
+		`i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done`,
+	))
+
+	assert.Zero(t, execution.UserTime())
+	assert.Zero(t, execution.SystemTime())
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.True(t, execution.UserTime() > 0)
+	assert.True(t, execution.SystemTime() >= 0)
+}
+
+func TestNoOutputInErrorKeepsOutputInStreamsData(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo secret; exit 1`),
+	).NoOutputInError()
+
+	err := execution.Run()
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), `─ secret`)
+
+	assert.Equal(t, []StreamData{
+		{Stream: Stdout, Data: []byte("secret\n")},
+	}, execution.GetStreamsData())
+}
+
+func TestSharedLogMutexPreventsTornLines(t *testing.T) {
+	var (
+		mutex  = &sync.Mutex{}
+		output = &bytes.Buffer{}
+	)
+
+	// writes byte by byte to make interleaving visible
+	logger := func(command []string, stream Stream, data []byte) {
+		for _, char := range append(data, '\n') {
+			output.WriteByte(char)
+		}
+	}
+
+	script := `for i in $(seq 1 50); do echo %s; done`
+
+	first := NewExec(
+		logger,
+		exec.Command(`sh`, `-c`, fmt.Sprintf(script, `aaaaaaaaaa`)),
+	).SetLogMutex(mutex)
+
+	second := NewExec(
+		logger,
+		exec.Command(`sh`, `-c`, fmt.Sprintf(script, `bbbbbbbbbb`)),
+	).SetLogMutex(mutex)
+
+	assert.NoError(t, first.Start())
+	assert.NoError(t, second.Start())
+	assert.NoError(t, first.Wait())
+	assert.NoError(t, second.Wait())
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Len(t, lines, 104)
+
+	for _, line := range lines {
+		assert.Contains(
+			t,
+			[]string{`launch`, `exit 0`, `aaaaaaaaaa`, `bbbbbbbbbb`},
+			line,
+		)
+	}
+}
+
 func assertCommandOutput(
 	t *testing.T,
 	command []string,
@@ -123,3 +374,309 @@ func assertCommandOutput(
 	assert.Equal(t, stderr, actualStderr.String())
 	assert.Equal(t, logged, log)
 }
+
+func TestLogLaunchAfterStartSkipsLaunchOfFailedStart(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	execution := NewExec(
+		Loggerf(logger),
+		exec.Command(`lexec-nonexistent-binary`),
+	).SetLogLaunchAfterStart(true)
+
+	err := execution.Start()
+	assert.Error(t, err)
+	assert.Empty(t, log)
+
+	execution = NewExec(
+		Loggerf(logger),
+		exec.Command(`true`),
+	).SetLogLaunchAfterStart(true)
+
+	err = execution.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`launch | true`,
+		`finish | true -> exit 0`,
+	}, log)
+}
+
+func TestLoggerFailureDoesNotAbortCapture(t *testing.T) {
+	var (
+		errs  []error
+		mutex sync.Mutex
+	)
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Stdout {
+			panic(syscall.EPIPE)
+		}
+	}
+
+	execution := NewExec(
+		logger,
+		exec.Command(`sh`, `-c`, `echo 1; echo 2 >&2; echo 3`),
+	).SetLogErrorHandler(func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		errs = append(errs, err)
+	})
+
+	stdout, stderr, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n3\n", string(stdout))
+	assert.Equal(t, "2\n", string(stderr))
+
+	assert.NotEmpty(t, errs)
+	assert.Contains(t, errs[0].Error(), `logger panicked on stdout`)
+	assert.Contains(t, errs[0].Error(), syscall.EPIPE.Error())
+}
+
+func TestLineNumbersArePrefixedPerStream(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(
+		Loggerf(logger),
+		exec.Command(
+			`sh`, `-c`,
+			`echo a; echo b >&2; echo c; echo d`,
+		),
+	).
+		SetLineNumbers(true).
+		Run()
+	assert.NoError(t, err)
+
+	// streams are read concurrently and lines can be logged as single block,
+	// so only order of lines within every stream is stable
+	var stdout, stderr []string
+	for _, entry := range log[1 : len(log)-1] {
+		switch {
+		case strings.HasPrefix(entry, `stdout |  `):
+			stdout = append(stdout, strings.TrimPrefix(entry, `stdout |  `))
+		case strings.HasPrefix(entry, `stderr |  `):
+			stderr = append(stderr, strings.TrimPrefix(entry, `stderr |  `))
+		}
+	}
+
+	assert.Equal(t, "1: a\n2: c\n3: d", strings.Join(stdout, "\n"))
+	assert.Equal(t, "1: b", strings.Join(stderr, "\n"))
+}
+
+func TestStringFormatChangesCommandRepresentation(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sh`, `-c`, `exit 1`))
+
+	assert.Equal(t, `["sh" "-c" "exit 1"]`, execution.String())
+
+	execution.SetStringFormat(FormatShellCommand)
+
+	assert.Equal(t, `sh -c "exit 1"`, execution.String())
+
+	err := execution.Run()
+	assert.Contains(t, err.Error(), `command: sh -c "exit 1"`)
+}
+
+func TestArgMaskMasksSecretsInLog(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	args := []string{`sh`, `-c`, `echo "$1"`, `-p`, `hunter2`}
+
+	execution := NewExec(Loggerf(logger), exec.Command(args[0], args[1:]...)).
+		SetArgMask(func(index int, arg string) string {
+			if index > 0 && args[index-1] == `-p` {
+				return `***`
+			}
+
+			return arg
+		})
+
+	stdout, err := execution.Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2\n", stdout)
+
+	assert.Equal(t, []string{
+		`launch | sh -c "echo \"\$1\"" -p ***`,
+		`stdout |  hunter2`,
+		`finish | sh -c "echo \"\$1\"" -p *** -> exit 0`,
+	}, log)
+	assert.NotContains(t, execution.String(), `hunter2`)
+}
+
+func TestCommandReturnsCommandPassedToNew(t *testing.T) {
+	replay := NewReplay(Recording{Args: []string{`replay`}})
+
+	assert.Same(t, replay, New(nil, replay).Command())
+}
+
+func TestErrorStreamLimitsOutputInError(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo no""ise; echo fail""ure >&2; exit 1`),
+	).
+		SetErrorStream(Stderr).
+		Run()
+	assert.True(t, IsExitStatus(err))
+	assert.Contains(t, err.Error(), `failure`)
+	assert.NotContains(t, err.Error(), `noise`)
+}
+
+func TestSortErrorOutputByStreamGroupsStdoutBeforeStderr(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo e""1 >&2; echo o""1; echo e""2 >&2; exit 1`,
+		),
+	).
+		SortErrorOutputByStream(true).
+		Run()
+	assert.True(t, IsExitStatus(err))
+
+	message := err.Error()
+
+	assert.Less(t, strings.Index(message, "o1"), strings.Index(message, "e1"))
+	assert.Less(t, strings.Index(message, "e1"), strings.Index(message, "e2"))
+}
+
+func TestMaxLoggedLinesTruncatesLogButNotCapture(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	stdout, _, err := NewExec(Loggerf(logger), exec.Command(`seq`, `10`)).
+		SetMaxLoggedLines(3).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", string(stdout))
+
+	var output []string
+	for _, entry := range log[1 : len(log)-1] {
+		output = append(output, strings.TrimPrefix(entry, `stdout |  `))
+	}
+
+	assert.Equal(t, "1\n2\n3\n(log truncated)", strings.Join(output, "\n"))
+}
+
+func TestMaxLogLineBytesSplitsLongLines(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	line := strings.Repeat(`a`, 10) + strings.Repeat(`b`, 10) + `ccccc`
+
+	stdout, err := NewExec(Loggerf(logger), exec.Command(`echo`, line)).
+		SetMaxLogLineBytes(10).
+		Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, line+"\n", stdout)
+
+	assert.Equal(t, []string{
+		`stdout |  aaaaaaaaaa...`,
+		`stdout |  bbbbbbbbbb...`,
+		`stdout |  ccccc`,
+	}, log[1:len(log)-1])
+}
+
+func TestConfigurationAfterStartPanics(t *testing.T) {
+	tests := map[string]func(*Execution){
+		`SetStdin`: func(execution *Execution) {
+			execution.SetStdin(strings.NewReader(``))
+		},
+		`SetStdout`: func(execution *Execution) {
+			execution.SetStdout(&bytes.Buffer{})
+		},
+		`SetStderr`: func(execution *Execution) {
+			execution.SetStderr(&bytes.Buffer{})
+		},
+		`SetStdoutFile`: func(execution *Execution) {
+			execution.SetStdoutFile(`/dev/null`)
+		},
+		`SetEnvMap`: func(execution *Execution) {
+			execution.SetEnvMap(map[string]string{`A`: `1`})
+		},
+		`AddEnv`: func(execution *Execution) {
+			execution.AddEnv(`A`, `1`)
+		},
+		`SetPath`: func(execution *Execution) {
+			execution.SetPath(`/bin`)
+		},
+		`SetUmask`: func(execution *Execution) {
+			execution.SetUmask(0077)
+		},
+		`SetStdinWriteTimeout`: func(execution *Execution) {
+			execution.SetStdinWriteTimeout(time.Second)
+		},
+		`SetStdinContext`: func(execution *Execution) {
+			execution.SetStdinContext(context.Background())
+		},
+		`LogStdin`: func(execution *Execution) {
+			execution.LogStdin()
+		},
+		`SpillToDiskAfter`: func(execution *Execution) {
+			execution.SpillToDiskAfter(1024)
+		},
+		`SetIdleTimeout`: func(execution *Execution) {
+			execution.SetIdleTimeout(time.Second)
+		},
+	}
+
+	for method, configure := range tests {
+		execution := NewExec(nil, exec.Command(`true`))
+
+		err := execution.Run()
+		assert.NoError(t, err)
+
+		assert.PanicsWithValue(
+			t,
+			`lexec: `+method+` called after Start: ["true"]`,
+			func() { configure(execution) },
+			method,
+		)
+	}
+}
+
+func TestLogIndentIsPrefixedToEveryOutputLine(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(
+		Loggerf(logger),
+		exec.Command(`sh`, `-c`, `echo a; echo b; echo c >&2`),
+	).
+		SetLogIndent(`    `).
+		Run()
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(log[0], `launch | sh -c`))
+
+	var stdout, stderr []string
+	for _, entry := range log[1 : len(log)-1] {
+		switch {
+		case strings.HasPrefix(entry, `stdout |  `):
+			stdout = append(stdout, strings.TrimPrefix(entry, `stdout |  `))
+		case strings.HasPrefix(entry, `stderr |  `):
+			stderr = append(stderr, strings.TrimPrefix(entry, `stderr |  `))
+		}
+	}
+
+	assert.Equal(t, "    a\n    b", strings.Join(stdout, "\n"))
+	assert.Equal(t, []string{`    c`}, stderr)
+}
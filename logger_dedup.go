@@ -0,0 +1,113 @@
+package lexec
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoggerDedup returns Logger which collapses consecutive identical stdout and
+// stderr lines of the same command logged within given window after the first
+// occurrence. Only first line is passed to the inner logger, repeats are
+// counted and `(repeated N times)` summary is logged once different line or
+// any other event of that command (e.g. Finish) arrives. Lines of different
+// commands sharing the logger are never collapsed together.
+func LoggerDedup(inner Logger, window time.Duration) Logger {
+	dedup := &loggerDedup{
+		inner:  inner,
+		window: window,
+		states: map[string]*loggerDedupState{},
+	}
+
+	return dedup.log
+}
+
+type loggerDedup struct {
+	inner  Logger
+	window time.Duration
+
+	mutex  sync.Mutex
+	states map[string]*loggerDedupState
+}
+
+type loggerDedupState struct {
+	stream  Stream
+	line    []byte
+	since   time.Time
+	repeats int
+}
+
+func (dedup *loggerDedup) log(command []string, stream Stream, data []byte) {
+	dedup.mutex.Lock()
+	defer dedup.mutex.Unlock()
+
+	key := fmt.Sprintf("%q", command)
+
+	if isNoOutputStream(stream) {
+		if state, ok := dedup.states[key]; ok {
+			dedup.flush(command, state)
+
+			delete(dedup.states, key)
+		}
+
+		dedup.inner(command, stream, data)
+
+		return
+	}
+
+	state, ok := dedup.states[key]
+	if !ok {
+		state = &loggerDedupState{}
+
+		dedup.states[key] = state
+	}
+
+	var passed [][]byte
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		now := time.Now()
+
+		if state.line != nil &&
+			state.stream == stream &&
+			bytes.Equal(state.line, line) &&
+			now.Sub(state.since) < dedup.window {
+			state.repeats++
+
+			continue
+		}
+
+		if state.repeats > 0 {
+			if len(passed) > 0 {
+				dedup.inner(command, stream, bytes.Join(passed, []byte("\n")))
+				passed = nil
+			}
+
+			dedup.flush(command, state)
+		}
+
+		state.stream = stream
+		state.line = append([]byte(nil), line...)
+		state.since = now
+
+		passed = append(passed, line)
+	}
+
+	if len(passed) > 0 {
+		dedup.inner(command, stream, bytes.Join(passed, []byte("\n")))
+	}
+}
+
+func (dedup *loggerDedup) flush(command []string, state *loggerDedupState) {
+	if state.repeats == 0 {
+		return
+	}
+
+	dedup.inner(
+		command,
+		state.stream,
+		[]byte(fmt.Sprintf("(repeated %d times)", state.repeats)),
+	)
+
+	state.repeats = 0
+}
@@ -0,0 +1,127 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerDedupCollapsesRepeatedLines(t *testing.T) {
+	var entries []string
+
+	logger := LoggerDedup(
+		func(command []string, stream Stream, data []byte) {
+			entries = append(entries, fmt.Sprintf("%s %s", stream, data))
+		},
+		time.Minute,
+	)
+
+	err := NewExec(
+		logger,
+		exec.Command(`printf`, `a\na\na\nb\nb\nc\n`),
+	).Run()
+	assert.NoError(t, err)
+
+	var lines []string
+	for _, entry := range entries[1 : len(entries)-1] {
+		stream, data, _ := strings.Cut(entry, " ")
+		for _, line := range strings.Split(data, "\n") {
+			lines = append(lines, stream+" "+line)
+		}
+	}
+
+	assert.Equal(
+		t,
+		[]string{
+			"stdout a",
+			"stdout (repeated 2 times)",
+			"stdout b",
+			"stdout (repeated 1 times)",
+			"stdout c",
+		},
+		lines,
+	)
+	assert.True(t, strings.HasPrefix(entries[0], string(Launch)))
+	assert.True(t, strings.HasPrefix(entries[len(entries)-1], string(Finish)))
+}
+
+func TestLoggerDedupFlushesRepeatsBeforeOtherEvents(t *testing.T) {
+	var entries []string
+
+	logger := LoggerDedup(
+		func(command []string, stream Stream, data []byte) {
+			entries = append(entries, fmt.Sprintf("%s %s", stream, data))
+		},
+		time.Minute,
+	)
+
+	logger(nil, Stderr, []byte("x"))
+	logger(nil, Stderr, []byte("x"))
+	logger(nil, Stdout, []byte("x"))
+	logger(nil, Stdout, []byte("x"))
+	logger(nil, Finish, []byte("done"))
+
+	assert.Equal(
+		t,
+		[]string{
+			"stderr x",
+			"stderr (repeated 1 times)",
+			"stdout x",
+			"stdout (repeated 1 times)",
+			"finish done",
+		},
+		entries,
+	)
+}
+
+func TestLoggerDedupPassesRepeatsOutsideWindow(t *testing.T) {
+	var entries []string
+
+	logger := LoggerDedup(
+		func(command []string, stream Stream, data []byte) {
+			entries = append(entries, fmt.Sprintf("%s %s", stream, data))
+		},
+		0,
+	)
+
+	logger(nil, Stdout, []byte("x"))
+	logger(nil, Stdout, []byte("x"))
+
+	assert.Equal(t, []string{"stdout x", "stdout x"}, entries)
+}
+
+func TestLoggerDedupDoesNotCollapseLinesOfDifferentCommands(t *testing.T) {
+	var entries []string
+
+	logger := LoggerDedup(
+		func(command []string, stream Stream, data []byte) {
+			entries = append(
+				entries,
+				fmt.Sprintf("%s %s %s", command[0], stream, data),
+			)
+		},
+		time.Minute,
+	)
+
+	logger([]string{`a`}, Stdout, []byte("x"))
+	logger([]string{`b`}, Stdout, []byte("x"))
+	logger([]string{`a`}, Stdout, []byte("x"))
+	logger([]string{`b`}, Finish, []byte("done"))
+	logger([]string{`a`}, Finish, []byte("done"))
+
+	assert.Equal(
+		t,
+		[]string{
+			"a stdout x",
+			"b stdout x",
+			"b finish done",
+			"a stdout (repeated 1 times)",
+			"a finish done",
+		},
+		entries,
+	)
+}
@@ -0,0 +1,50 @@
+//go:build go1.21
+// +build go1.21
+
+package lexec
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LoggerSlog returns Logger that writes every event as structured record
+// into given slog.Logger with attributes `command`, `stream` and `data`.
+//
+// Stderr output is logged with Warn level, everything else with Info level.
+func LoggerSlog(logger *slog.Logger) Logger {
+	fieldsLogger := LoggerSlogFields(logger)
+
+	return func(command []string, stream Stream, data []byte) {
+		fieldsLogger(command, stream, data, nil)
+	}
+}
+
+// LoggerSlogFields is same as LoggerSlog, but returns FieldsLogger which can
+// be set via SetFieldsLogger and adds fields, like ones set via
+// SetLogContext, as separate attributes.
+func LoggerSlogFields(logger *slog.Logger) FieldsLogger {
+	return func(
+		command []string,
+		stream Stream,
+		data []byte,
+		fields []LogField,
+	) {
+		level := slog.LevelInfo
+		if stream == Stderr {
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.Any("command", command),
+			slog.String("stream", string(stream)),
+			slog.String("data", string(data)),
+		}
+
+		for _, field := range fields {
+			attrs = append(attrs, slog.String(field.Key, field.Value))
+		}
+
+		logger.LogAttrs(context.Background(), level, string(stream), attrs...)
+	}
+}
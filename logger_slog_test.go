@@ -0,0 +1,110 @@
+//go:build go1.21
+// +build go1.21
+
+package lexec
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type slogRecorder struct {
+	records []slog.Record
+}
+
+func (recorder *slogRecorder) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (recorder *slogRecorder) Handle(
+	_ context.Context,
+	record slog.Record,
+) error {
+	recorder.records = append(recorder.records, record)
+	return nil
+}
+
+func (recorder *slogRecorder) WithAttrs([]slog.Attr) slog.Handler {
+	return recorder
+}
+
+func (recorder *slogRecorder) WithGroup(string) slog.Handler {
+	return recorder
+}
+
+func TestLoggerSlogLogsStructuredRecords(t *testing.T) {
+	recorder := &slogRecorder{}
+
+	execution := NewExec(
+		LoggerSlog(slog.New(recorder)),
+		exec.Command(`sh`, `-c`, `echo 1; echo 2 >&2`),
+	)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	type entry struct {
+		level   slog.Level
+		command interface{}
+		stream  string
+		data    string
+	}
+
+	var entries []entry
+	for _, record := range recorder.records {
+		item := entry{level: record.Level}
+
+		record.Attrs(func(attr slog.Attr) bool {
+			switch attr.Key {
+			case "command":
+				item.command = attr.Value.Any()
+			case "stream":
+				item.stream = attr.Value.String()
+			case "data":
+				item.data = attr.Value.String()
+			}
+
+			return true
+		})
+
+		entries = append(entries, item)
+	}
+
+	command := []string{`sh`, `-c`, `echo 1; echo 2 >&2`}
+
+	assert.Len(t, entries, 4)
+	assert.Equal(t, entry{slog.LevelInfo, command, `launch`, `launch`}, entries[0])
+	assert.Contains(t, entries[1:3], entry{slog.LevelInfo, command, `stdout`, `1`})
+	assert.Contains(t, entries[1:3], entry{slog.LevelWarn, command, `stderr`, `2`})
+	assert.Equal(t, entry{slog.LevelInfo, command, `finish`, `exit 0`}, entries[3])
+}
+
+func TestLoggerSlogFieldsAddsLogContextAttributes(t *testing.T) {
+	recorder := &slogRecorder{}
+
+	err := NewExec(nil, exec.Command(`echo`, `1`)).
+		SetFieldsLogger(LoggerSlogFields(slog.New(recorder))).
+		SetLogContext(map[string]string{"host": "web-1", "dir": "/srv"}).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Len(t, recorder.records, 3)
+
+	for _, record := range recorder.records {
+		attrs := map[string]string{}
+
+		record.Attrs(func(attr slog.Attr) bool {
+			attrs[attr.Key] = attr.Value.String()
+
+			return true
+		})
+
+		assert.Equal(t, "web-1", attrs["host"])
+		assert.Equal(t, "/srv", attrs["dir"])
+		assert.NotContains(t, attrs["data"], "web-1")
+	}
+}
@@ -0,0 +1,79 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package lexec
+
+import (
+	"fmt"
+	"log/syslog"
+
+	"github.com/reconquest/karma-go"
+)
+
+const syslogSeverityMask = 0x07
+
+// LoggerSyslog returns Logger that sends every event to the system logger
+// with given priority and tag. Events are formatted same way as Loggerf does.
+//
+// Stderr output is sent with severity one level higher than severity of
+// given priority, e.g. LOG_WARNING instead of LOG_NOTICE. Send errors are
+// ignored.
+func LoggerSyslog(priority syslog.Priority, tag string) (Logger, error) {
+	writer, err := syslog.New(priority, tag)
+	if err != nil {
+		return nil, karma.Describe("tag", tag).Format(
+			err,
+			`can't connect to system logger`,
+		)
+	}
+
+	return loggerSyslog(
+		priority,
+		func(severity syslog.Priority, message string) error {
+			return writeSyslog(writer, severity, message)
+		},
+	), nil
+}
+
+func loggerSyslog(
+	priority syslog.Priority,
+	write func(severity syslog.Priority, message string) error,
+) Logger {
+	severity := priority & syslogSeverityMask
+
+	return func(command []string, stream Stream, data []byte) {
+		level := severity
+		if stream == Stderr && level > syslog.LOG_EMERG {
+			level--
+		}
+
+		Loggerf(func(format string, args ...interface{}) {
+			_ = write(level, fmt.Sprintf(format, args...))
+		})(command, stream, data)
+	}
+}
+
+func writeSyslog(
+	writer *syslog.Writer,
+	severity syslog.Priority,
+	message string,
+) error {
+	switch severity {
+	case syslog.LOG_EMERG:
+		return writer.Emerg(message)
+	case syslog.LOG_ALERT:
+		return writer.Alert(message)
+	case syslog.LOG_CRIT:
+		return writer.Crit(message)
+	case syslog.LOG_ERR:
+		return writer.Err(message)
+	case syslog.LOG_WARNING:
+		return writer.Warning(message)
+	case syslog.LOG_NOTICE:
+		return writer.Notice(message)
+	case syslog.LOG_INFO:
+		return writer.Info(message)
+	default:
+		return writer.Debug(message)
+	}
+}
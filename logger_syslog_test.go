@@ -0,0 +1,55 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package lexec
+
+import (
+	"log/syslog"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerSyslogMapsStderrToHigherSeverity(t *testing.T) {
+	type entry struct {
+		severity syslog.Priority
+		message  string
+	}
+
+	var (
+		entries []entry
+		mutex   sync.Mutex
+	)
+
+	logger := loggerSyslog(
+		syslog.LOG_DAEMON|syslog.LOG_NOTICE,
+		func(severity syslog.Priority, message string) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			entries = append(entries, entry{severity, message})
+
+			return nil
+		},
+	)
+
+	err := NewExec(logger, exec.Command(`sh`, `-c`, `echo 1; echo 2 >&2`)).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Len(t, entries, 4)
+	assert.Equal(
+		t,
+		entry{syslog.LOG_NOTICE, `launch | sh -c "echo 1; echo 2 >&2"`},
+		entries[0],
+	)
+	assert.Contains(t, entries[1:3], entry{syslog.LOG_NOTICE, `stdout |  1`})
+	assert.Contains(t, entries[1:3], entry{syslog.LOG_WARNING, `stderr |  2`})
+	assert.Equal(
+		t,
+		entry{syslog.LOG_NOTICE, `finish | sh -c "echo 1; echo 2 >&2" -> exit 0`},
+		entries[3],
+	)
+}
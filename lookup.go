@@ -0,0 +1,149 @@
+package lexec
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// Exists returns true if given command can be found in PATH or, if name
+// contains slash, exists and is executable.
+func Exists(name string) bool {
+	_, err := exec.LookPath(name)
+
+	return err == nil
+}
+
+// CheckExists returns CommandNotFoundError if command binary can't be
+// resolved. Check is performed only for commands created via NewExec. Path set
+// via SetPath is used instead of PATH of current process. Names containing
+// path separator are resolved relative to directory of the command, same as
+// exec.Cmd does.
+func (execution *Execution) CheckExists() error {
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return nil
+	}
+
+	name := cmd.Path
+	if len(cmd.Args) > 0 {
+		name = cmd.Args[0]
+	}
+
+	if name == "" {
+		return CommandNotFoundError{
+			Karma: karma.Describe("command", execution.String()).Format(
+				nil,
+				`command name is empty`,
+			),
+		}
+	}
+
+	_, err := lookPath(getLookupName(name, cmd.Dir), execution.path)
+	if err != nil {
+		return CommandNotFoundError{
+			Karma: karma.Describe("command", execution.String()).Format(
+				err,
+				`command not found: %s`,
+				name,
+			),
+			Name: name,
+		}
+	}
+
+	return nil
+}
+
+// getLookupName returns name of command binary relative to current directory.
+func getLookupName(name string, dir string) string {
+	if dir == "" ||
+		filepath.IsAbs(name) ||
+		!strings.ContainsRune(name, filepath.Separator) {
+		return name
+	}
+
+	name = filepath.Join(dir, name)
+	if !strings.ContainsRune(name, filepath.Separator) {
+		name = "." + string(filepath.Separator) + name
+	}
+
+	return name
+}
+
+// SetPath sets PATH environment variable of the command and makes command
+// binary to be resolved using given path instead of PATH of current process.
+// Supported only for commands created via NewExec.
+func (execution *Execution) SetPath(path string) *Execution {
+	execution.mustNotBeStarted(`SetPath`)
+
+	execution.path = path
+
+	return execution
+}
+
+func (execution *Execution) setupPath() error {
+	if execution.path == "" {
+		return nil
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return karma.Format(
+			nil,
+			`path can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	env := []string{}
+	for _, item := range getEnv(cmd) {
+		if key, _ := splitEnv(item); key != "PATH" {
+			env = append(env, item)
+		}
+	}
+
+	cmd.Env = append(env, "PATH="+execution.path)
+
+	err := execution.CheckExists()
+	if err != nil {
+		return err
+	}
+
+	// names with separator are resolved by exec.Cmd relative to Dir
+	if len(cmd.Args) > 0 &&
+		!strings.ContainsRune(cmd.Args[0], filepath.Separator) {
+		cmd.Path, _ = lookPath(cmd.Args[0], execution.path)
+	}
+
+	// error of lookup in PATH of current process
+	cmd.Err = nil
+
+	return nil
+}
+
+// lookPath resolves binary in given path, PATH of current process is used if
+// path is empty.
+func lookPath(name string, path string) (string, error) {
+	if path == "" || strings.ContainsRune(name, filepath.Separator) {
+		return exec.LookPath(name)
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, name)
+		if !strings.ContainsRune(candidate, filepath.Separator) {
+			candidate = "." + string(filepath.Separator) + candidate
+		}
+
+		resolved, err := exec.LookPath(candidate)
+		if err == nil {
+			return resolved, nil
+		}
+	}
+
+	return "", karma.Describe("path", path).Format(
+		exec.ErrNotFound,
+		`executable file not found`,
+	)
+}
@@ -0,0 +1,92 @@
+package lexec
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExistsChecksCommandInPath(t *testing.T) {
+	assert.True(t, Exists(`sh`))
+	assert.False(t, Exists(`lexec-nonexistent-command`))
+}
+
+func TestCheckExistsReturnsCommandNotFoundError(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sh`)).CheckExists()
+	assert.NoError(t, err)
+
+	err = NewExec(nil, exec.Command(`lexec-nonexistent-command`)).
+		CheckExists()
+	assert.Error(t, err)
+
+	notFound, ok := err.(CommandNotFoundError)
+	assert.True(t, ok)
+	assert.Equal(t, `lexec-nonexistent-command`, notFound.Name)
+}
+
+func TestSetPathResolvesBinaryInGivenPath(t *testing.T) {
+	dir := t.TempDir()
+
+	err := ioutil.WriteFile(
+		filepath.Join(dir, `lexec-custom-command`),
+		[]byte("#!/bin/sh\necho \"$PATH\"\n"),
+		0755,
+	)
+	assert.NoError(t, err)
+
+	assert.False(t, Exists(`lexec-custom-command`))
+
+	path := dir + string(filepath.ListSeparator) + `/bin`
+
+	execution := NewExec(nil, exec.Command(`lexec-custom-command`)).
+		SetPath(path)
+
+	assert.NoError(t, execution.CheckExists())
+
+	stdout, err := execution.Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, path+"\n", stdout)
+
+	err = NewExec(nil, exec.Command(`sh`, `-c`, `true`)).
+		SetPath(dir).
+		Run()
+	assert.Error(t, err)
+	assert.IsType(t, CommandNotFoundError{}, err)
+}
+
+func TestCheckExistsResolvesRelativeNameInCommandDir(t *testing.T) {
+	dir := t.TempDir()
+
+	err := ioutil.WriteFile(
+		filepath.Join(dir, `script`),
+		[]byte("#!/bin/sh\necho ok\n"),
+		0755,
+	)
+	assert.NoError(t, err)
+
+	cmd := exec.Command(`./script`)
+	cmd.Dir = dir
+
+	execution := NewExec(nil, cmd).SetPath(`/bin`)
+
+	assert.NoError(t, execution.CheckExists())
+
+	stdout, err := execution.Stdout()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", stdout)
+}
+
+func TestCheckExistsReturnsErrorForEmptyArgs(t *testing.T) {
+	execution := NewExec(nil, &exec.Cmd{})
+
+	err := execution.CheckExists()
+	assert.IsType(t, CommandNotFoundError{}, err)
+
+	execution = NewExec(nil, &exec.Cmd{Path: `/bin/true`}).SetPath(`/bin`)
+
+	assert.NoError(t, execution.CheckExists())
+	assert.NoError(t, execution.Run())
+}
@@ -0,0 +1,55 @@
+package lexec
+
+import "time"
+
+// Metrics represents summary of finished execution.
+type Metrics struct {
+	// ExitCode is exit code of the command or -1 if command has not exited
+	// normally.
+	ExitCode int
+
+	// Duration is time passed between Start and the end of Wait.
+	Duration time.Duration
+
+	// StdoutBytes is amount of bytes written by command to stdout.
+	StdoutBytes int64
+
+	// StderrBytes is amount of bytes written by command to stderr.
+	StderrBytes int64
+
+	// TimedOut is true if command has been killed due to timeout.
+	TimedOut bool
+}
+
+// SetMetricsSink sets function which will be called once at the end of Wait
+// with metrics of the execution.
+func (execution *Execution) SetMetricsSink(sink func(Metrics)) *Execution {
+	execution.metricsSink = sink
+
+	return execution
+}
+
+func (execution *Execution) emitMetrics(err error) {
+	if execution.metricsSink == nil {
+		return
+	}
+
+	metrics := Metrics{
+		ExitCode: execution.getExitCode(err),
+		Duration: execution.clock.Now().Sub(execution.startedAt),
+		TimedOut: execution.WasTimedOut(),
+	}
+
+	execution.combinedMutex.Lock()
+	for _, data := range execution.combinedStreams {
+		switch data.Stream {
+		case Stdout:
+			metrics.StdoutBytes += int64(len(data.Data))
+		case Stderr:
+			metrics.StderrBytes += int64(len(data.Data))
+		}
+	}
+	execution.combinedMutex.Unlock()
+
+	execution.metricsSink(metrics)
+}
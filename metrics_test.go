@@ -0,0 +1,30 @@
+package lexec
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsSinkReceivesExecutionMetrics(t *testing.T) {
+	var metrics []Metrics
+
+	err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `printf 123; printf 45 >&2; sleep 0.1; exit 2`),
+	).
+		SetMetricsSink(func(m Metrics) {
+			metrics = append(metrics, m)
+		}).
+		Run()
+	assert.Error(t, err)
+
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, 2, metrics[0].ExitCode)
+	assert.Equal(t, int64(3), metrics[0].StdoutBytes)
+	assert.Equal(t, int64(2), metrics[0].StderrBytes)
+	assert.False(t, metrics[0].TimedOut)
+	assert.True(t, metrics[0].Duration >= 100*time.Millisecond)
+}
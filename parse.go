@@ -0,0 +1,97 @@
+package lexec
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// Parse splits given shell-like command line into arguments and returns
+// execution of resulting command. Shell is not invoked.
+//
+// Arguments are separated by whitespace. Single quotes preserve everything
+// literally, double quotes preserve everything except backslash escapes of
+// `"`, `\`, `$` and backtick, and backslash outside quotes escapes next
+// character. Variables, globs, pipes and other shell features are not
+// supported.
+func Parse(logger Logger, commandline string) (*Execution, error) {
+	args, err := splitCommandLine(commandline)
+	if err != nil {
+		return nil, karma.Describe("commandline", commandline).Format(
+			err,
+			`can't parse command line`,
+		)
+	}
+
+	if len(args) == 0 {
+		return nil, karma.Describe("commandline", commandline).Format(
+			nil,
+			`command line is empty`,
+		)
+	}
+
+	return NewExec(logger, exec.Command(args[0], args[1:]...)), nil
+}
+
+func splitCommandLine(commandline string) ([]string, error) {
+	var (
+		args []string
+		arg  strings.Builder
+
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, char := range commandline {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", char) {
+				arg.WriteRune('\\')
+			}
+
+			arg.WriteRune(char)
+			escaped = false
+
+		case char == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0 && char == quote:
+			quote = 0
+
+		case quote != 0:
+			arg.WriteRune(char)
+
+		case char == '\'' || char == '"':
+			quote = char
+			inArg = true
+
+		case char == ' ' || char == '\t' || char == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(char)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, karma.Format(nil, `unexpected end after backslash`)
+	}
+
+	if quote != 0 {
+		return nil, karma.Format(nil, `unterminated %c quote`, quote)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
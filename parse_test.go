@@ -0,0 +1,37 @@
+package lexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSplitsQuotedArguments(t *testing.T) {
+	execution, err := Parse(nil, `git commit -m "hello world"`)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{`git`, `commit`, `-m`, `hello world`},
+		execution.Command().GetArgs(),
+	)
+}
+
+func TestParseHandlesQuotesAndEscapes(t *testing.T) {
+	tests := map[string][]string{
+		`a\ b 'c d' "e \"f\" \g"`: {`a b`, `c d`, `e "f" \g`},
+		`  x  ''  "" 'it'\''s'  `: {`x`, ``, ``, `it's`},
+		`a"b"'c'd`:                {`abcd`},
+		`'\n' "\\" \$HOME`:        {`\n`, `\`, `$HOME`},
+	}
+
+	for commandline, expected := range tests {
+		execution, err := Parse(nil, commandline)
+		assert.NoError(t, err, commandline)
+		assert.Equal(t, expected, execution.Command().GetArgs(), commandline)
+	}
+
+	for _, commandline := range []string{``, `  `, `a "b`, `a 'b`, `a\`} {
+		_, err := Parse(nil, commandline)
+		assert.Error(t, err, commandline)
+	}
+}
@@ -0,0 +1,81 @@
+package lexec
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// SetSetsid makes command run in new session (SysProcAttr.Setsid).
+// Supported only on Unix and only for commands created via NewExec.
+func (execution *Execution) SetSetsid(enabled bool) *Execution {
+	execution.mustNotBeStarted(`SetSetsid`)
+
+	execution.setsid = enabled
+
+	return execution
+}
+
+// SetSetpgid makes command run in new process group (SysProcAttr.Setpgid).
+// Supported only on Unix and only for commands created via NewExec. Can't be
+// combined with SetSetsid or Detach.
+func (execution *Execution) SetSetpgid(enabled bool) *Execution {
+	execution.mustNotBeStarted(`SetSetpgid`)
+
+	execution.setpgid = enabled
+
+	return execution
+}
+
+// SetForeground makes process group of the command foreground process group
+// of controlling terminal (SysProcAttr.Foreground). Supported only on Unix
+// and only for commands created via NewExec. Can't be combined with SetSetsid
+// or Detach.
+func (execution *Execution) SetForeground(enabled bool) *Execution {
+	execution.mustNotBeStarted(`SetForeground`)
+
+	execution.foreground = enabled
+
+	return execution
+}
+
+func (execution *Execution) setupProcAttr() error {
+	if !execution.setsid && !execution.setpgid && !execution.foreground {
+		return nil
+	}
+
+	if !procAttrSupported {
+		return karma.Format(
+			nil,
+			`process attributes are not supported on this platform: %s`,
+			execution.String(),
+		)
+	}
+
+	cmd, ok := execution.command.(*command)
+	if !ok {
+		return karma.Format(
+			nil,
+			`process attributes can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	// process which is a group leader can't create new session
+	newSession := execution.setsid || execution.detach
+	if newSession && (execution.setpgid || execution.foreground) {
+		return karma.Format(
+			nil,
+			`new session can't be combined with new process group or `+
+				`foreground: %s`,
+			execution.String(),
+		)
+	}
+
+	setProcAttr(
+		cmd.Cmd,
+		execution.setsid,
+		execution.setpgid,
+		execution.foreground,
+	)
+
+	return nil
+}
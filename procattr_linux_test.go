@@ -0,0 +1,35 @@
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetpgidRunsCommandInNewProcessGroup(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`sleep`, `10`)).SetSetpgid(true)
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	pid := execution.Process().Pid
+
+	pgid, err := syscall.Getpgid(pid)
+	assert.NoError(t, err)
+	assert.Equal(t, pid, pgid)
+	assert.NotEqual(t, syscall.Getpgrp(), pgid)
+
+	_ = execution.Process().Kill()
+	_ = execution.Wait()
+}
+
+func TestSetsidCantBeCombinedWithSetpgid(t *testing.T) {
+	err := NewExec(nil, exec.Command(`true`)).
+		SetSetsid(true).
+		SetSetpgid(true).
+		Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `new session can't be combined`)
+}
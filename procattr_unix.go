@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+const procAttrSupported = true
+
+func setProcAttr(cmd *exec.Cmd, setsid, setpgid, foreground bool) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setsid = setsid
+	cmd.SysProcAttr.Setpgid = setpgid
+	cmd.SysProcAttr.Foreground = foreground
+}
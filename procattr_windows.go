@@ -0,0 +1,9 @@
+package lexec
+
+import (
+	"os/exec"
+)
+
+const procAttrSupported = false
+
+func setProcAttr(cmd *exec.Cmd, setsid, setpgid, foreground bool) {}
@@ -0,0 +1,96 @@
+package lexec
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SetOutputRateLimit limits rate of consuming command output (stdout and
+// stderr together) to given number of bytes per second. When command writes
+// faster, it will be blocked on writing output. Zero disables limit.
+func (execution *Execution) SetOutputRateLimit(bytesPerSecond int) *Execution {
+	execution.mustNotBeStarted(`SetOutputRateLimit`)
+
+	execution.outputRateLimit = bytesPerSecond
+
+	return execution
+}
+
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    int
+	clock   clock
+	started time.Time
+	total   int64
+}
+
+// wait blocks until given amount of bytes can be passed without exceeding
+// rate limit.
+func (limiter *rateLimiter) wait(size int) {
+	limiter.mutex.Lock()
+
+	now := limiter.clock.Now()
+
+	if limiter.started.IsZero() {
+		limiter.started = now
+	}
+
+	limiter.total += int64(size)
+
+	deadline := limiter.started.Add(
+		time.Duration(limiter.total) * time.Second / time.Duration(limiter.rate),
+	)
+
+	limiter.mutex.Unlock()
+
+	delay := deadline.Sub(now)
+	if delay <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+
+	limiter.clock.AfterFunc(delay, func() {
+		close(done)
+	})
+
+	<-done
+}
+
+type rateLimitedWriter struct {
+	writer  io.Writer
+	limiter *rateLimiter
+}
+
+func (writer *rateLimitedWriter) Write(data []byte) (int, error) {
+	written := 0
+
+	for written < len(data) {
+		size := len(data) - written
+		if size > writer.limiter.rate {
+			size = writer.limiter.rate
+		}
+
+		writer.limiter.wait(size)
+
+		_, err := writer.writer.Write(data[written : written+size])
+		if err != nil {
+			return written, err
+		}
+
+		written += size
+	}
+
+	return written, nil
+}
+
+func newRateLimitedWriter(
+	writer io.Writer,
+	limiter *rateLimiter,
+) io.Writer {
+	return &rateLimitedWriter{
+		writer:  writer,
+		limiter: limiter,
+	}
+}
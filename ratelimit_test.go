@@ -0,0 +1,31 @@
+package lexec
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputRateLimitSlowsDownProducer(t *testing.T) {
+	clock := newFakeClock()
+
+	execution := NewExec(
+		nil,
+		exec.Command(`head`, `-c`, `30000`, `/dev/zero`),
+	).SetOutputRateLimit(100000)
+
+	execution.clock = clock
+
+	stop := advanceOnSchedule(clock)
+	defer stop()
+
+	started := clock.Now()
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Len(t, stdout, 30000)
+
+	assert.Equal(t, 300*time.Millisecond, clock.Now().Sub(started))
+}
@@ -0,0 +1,273 @@
+package lexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+	"github.com/reconquest/nopio-go"
+)
+
+// Recording represents single recorded execution. Truncated is true if
+// Stdout and Stderr hold only part of output, see SpillToDiskAfter.
+type Recording struct {
+	Args      []string `json:"args"`
+	Stdin     []byte   `json:"stdin,omitempty"`
+	Stdout    []byte   `json:"stdout,omitempty"`
+	Stderr    []byte   `json:"stderr,omitempty"`
+	ExitCode  int      `json:"exit_code"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// Recorder stores recorded executions.
+type Recorder interface {
+	Record(recording Recording) error
+}
+
+// SetRecorder sets recorder which receives argv, stdin, stdout, stderr and
+// exit code of command after it is finished. Recorded executions can be
+// replayed later using NewReplay without running anything.
+//
+// If recorder fails, error is returned by Wait unless command itself failed.
+func (execution *Execution) SetRecorder(recorder Recorder) *Execution {
+	execution.mustNotBeStarted(`SetRecorder`)
+
+	execution.recorder = recorder
+	execution.recordMutex = &sync.Mutex{}
+
+	return execution
+}
+
+func (execution *Execution) recordStdin(data []byte) {
+	execution.recordMutex.Lock()
+	defer execution.recordMutex.Unlock()
+
+	execution.recordedStdin = append(execution.recordedStdin, data...)
+}
+
+func (execution *Execution) record(err error) error {
+	if execution.recorder == nil {
+		return err
+	}
+
+	recording := Recording{
+		Args:     execution.command.GetArgs(),
+		ExitCode: execution.getExitCode(err),
+	}
+
+	execution.recordMutex.Lock()
+	recording.Stdin = execution.recordedStdin
+	execution.recordMutex.Unlock()
+
+	execution.combinedMutex.Lock()
+	for _, data := range execution.combinedStreams {
+		switch data.Stream {
+		case Stdout:
+			recording.Stdout = append(recording.Stdout, data.Data...)
+		case Stderr:
+			recording.Stderr = append(recording.Stderr, data.Data...)
+		}
+	}
+	recording.Truncated = execution.streamsTruncated
+	execution.combinedMutex.Unlock()
+
+	recordErr := execution.recorder.Record(recording)
+	if recordErr != nil && err == nil {
+		return karma.Format(
+			recordErr,
+			`can't record execution: %s`,
+			execution.String(),
+		)
+	}
+
+	return err
+}
+
+// FileRecorder is Recorder which stores recordings as JSON array in file.
+type FileRecorder struct {
+	path  string
+	mutex sync.Mutex
+}
+
+var _ Recorder = (*FileRecorder)(nil)
+
+// NewFileRecorder returns Recorder which stores recordings in given file.
+// File is created on first record.
+func NewFileRecorder(path string) *FileRecorder {
+	return &FileRecorder{path: path}
+}
+
+// Record appends recording to file.
+func (recorder *FileRecorder) Record(recording Recording) error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recordings, err := recorder.load()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(
+		append(recordings, recording),
+		"",
+		"    ",
+	)
+	if err != nil {
+		return karma.Format(err, `can't encode recordings`)
+	}
+
+	err = ioutil.WriteFile(recorder.path, data, 0644)
+	if err != nil {
+		return karma.Describe("path", recorder.path).Format(
+			err,
+			`can't write recordings file`,
+		)
+	}
+
+	return nil
+}
+
+// Load returns all recordings stored in file.
+func (recorder *FileRecorder) Load() ([]Recording, error) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.load()
+}
+
+// Replay returns Command which replays first recording with given argv.
+func (recorder *FileRecorder) Replay(args ...string) (Command, error) {
+	recordings, err := recorder.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recording := range recordings {
+		if reflect.DeepEqual(recording.Args, args) {
+			return NewReplay(recording), nil
+		}
+	}
+
+	return nil, karma.
+		Describe("path", recorder.path).
+		Format(
+			nil,
+			`no recording found for command: %s`,
+			FormatShellCommand(args),
+		)
+}
+
+func (recorder *FileRecorder) load() ([]Recording, error) {
+	data, err := ioutil.ReadFile(recorder.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, karma.Describe("path", recorder.path).Format(
+			err,
+			`can't read recordings file`,
+		)
+	}
+
+	var recordings []Recording
+
+	err = json.Unmarshal(data, &recordings)
+	if err != nil {
+		return nil, karma.Describe("path", recorder.path).Format(
+			err,
+			`can't decode recordings file`,
+		)
+	}
+
+	return recordings, nil
+}
+
+// NewReplay returns Command which serves recorded stdout, stderr and exit
+// code without running anything. Stdin is not read.
+func NewReplay(recording Recording) Command {
+	return &replayCommand{
+		recording: recording,
+		stdout:    ioutil.Discard,
+		stderr:    ioutil.Discard,
+	}
+}
+
+var _ Command = (*replayCommand)(nil)
+
+type replayCommand struct {
+	recording Recording
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+type replayExitError struct {
+	code int
+}
+
+func (err *replayExitError) Error() string {
+	return "exit status " + strconv.Itoa(err.code)
+}
+
+func (command *replayCommand) GetArgs() []string {
+	return command.recording.Args
+}
+
+func (command *replayCommand) Run() error {
+	err := command.Start()
+	if err != nil {
+		return err
+	}
+
+	return command.Wait()
+}
+
+func (command *replayCommand) Start() error {
+	_, err := command.stdout.Write(command.recording.Stdout)
+	if err != nil {
+		return err
+	}
+
+	_, err = command.stderr.Write(command.recording.Stderr)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (command *replayCommand) Wait() error {
+	if command.recording.ExitCode != 0 {
+		return &replayExitError{code: command.recording.ExitCode}
+	}
+
+	return nil
+}
+
+func (command *replayCommand) SetStdin(io.Reader) {}
+
+func (command *replayCommand) SetStdout(target io.Writer) {
+	command.stdout = target
+}
+
+func (command *replayCommand) SetStderr(target io.Writer) {
+	command.stderr = target
+}
+
+func (command *replayCommand) StdinPipe() (io.WriteCloser, error) {
+	return nopio.NopWriteCloser{}, nil
+}
+
+func (command *replayCommand) StdoutPipe() (io.Reader, error) {
+	return bytes.NewReader(command.recording.Stdout), nil
+}
+
+func (command *replayCommand) StderrPipe() (io.Reader, error) {
+	return bytes.NewReader(command.recording.Stderr), nil
+}
@@ -0,0 +1,60 @@
+package lexec
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorderRecordsExecutionWhichCanBeReplayed(t *testing.T) {
+	recorder := NewFileRecorder(
+		filepath.Join(t.TempDir(), "recordings.json"),
+	)
+
+	err := NewExec(nil, exec.Command(`echo`, `hello`)).
+		SetRecorder(recorder).
+		Run()
+	assert.NoError(t, err)
+
+	err = NewExec(nil, exec.Command(`sh`, `-c`, `cat; echo oops >&2; exit 3`)).
+		SetStdin(strings.NewReader("input\n")).
+		SetRecorder(recorder).
+		Run()
+	assert.Error(t, err)
+
+	recordings, err := recorder.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []Recording{
+		{
+			Args:   []string{`echo`, `hello`},
+			Stdout: []byte("hello\n"),
+		},
+		{
+			Args:     []string{`sh`, `-c`, `cat; echo oops >&2; exit 3`},
+			Stdin:    []byte("input\n"),
+			Stdout:   []byte("input\n"),
+			Stderr:   []byte("oops\n"),
+			ExitCode: 3,
+		},
+	}, recordings)
+
+	replay, err := recorder.Replay(`echo`, `hello`)
+	assert.NoError(t, err)
+
+	stdout, _, err := New(nil, replay).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(stdout))
+
+	replay, err = recorder.Replay(`sh`, `-c`, `cat; echo oops >&2; exit 3`)
+	assert.NoError(t, err)
+
+	_, stderr, err := New(nil, replay).Output()
+	assert.Equal(t, 3, GetExitStatus(err))
+	assert.Equal(t, "oops\n", string(stderr))
+
+	_, err = recorder.Replay(`echo`, `bye`)
+	assert.Error(t, err)
+}
@@ -6,9 +6,30 @@ import (
 	"strings"
 )
 
+// Shell represents shell which quoting rules are used to format command.
+type Shell string
+
+const (
+	// ShellSh is POSIX sh.
+	ShellSh Shell = `sh`
+
+	// ShellPowerShell is Windows PowerShell.
+	ShellPowerShell Shell = `powershell`
+
+	// ShellCmd is Windows cmd.exe.
+	ShellCmd Shell = `cmd`
+)
+
 var (
 	reSpecialChars       = regexp.MustCompile("[$`\"!'\\s]")
 	reSpecialCharsEscape = regexp.MustCompile("[$`\"!]")
+
+	rePowerShellSpecialChars = regexp.MustCompile("[\\s'\"$`(){}\\[\\];,&|<>@#]")
+
+	reCmdSpecialChars = regexp.MustCompile(`[\s"&|<>^()%!]`)
+	reCmdQuote        = regexp.MustCompile(`(\\*)"`)
+	reCmdTrailing     = regexp.MustCompile(`(\\+)$`)
+	reCmdExpansion    = regexp.MustCompile(`[%!]`)
 )
 
 func FormatShellCommand(command []string) string {
@@ -27,3 +48,51 @@ func FormatShellCommand(command []string) string {
 
 	return strings.Join(safe, " ")
 }
+
+// FormatShellCommandFor formats command according to quoting rules of the
+// given shell, so it can be pasted into that shell. Unknown shell is
+// treated as ShellSh.
+func FormatShellCommandFor(shell Shell, command []string) string {
+	var quote func(string) string
+
+	switch shell {
+	case ShellPowerShell:
+		quote = quotePowerShell
+	case ShellCmd:
+		quote = quoteCmd
+	default:
+		return FormatShellCommand(command)
+	}
+
+	var safe []string
+
+	for _, arg := range command {
+		safe = append(safe, quote(arg))
+	}
+
+	return strings.Join(safe, " ")
+}
+
+func quotePowerShell(arg string) string {
+	if arg != "" && !rePowerShellSpecialChars.MatchString(arg) {
+		return arg
+	}
+
+	return `'` + strings.ReplaceAll(arg, `'`, `''`) + `'`
+}
+
+// quoteCmd quotes argument according to rules of CommandLineToArgvW:
+// backslashes are doubled only before quotes. Percent and exclamation signs
+// are escaped with caret, because cmd.exe expands %VAR% and !VAR! even
+// inside quotes.
+func quoteCmd(arg string) string {
+	if arg != "" && !reCmdSpecialChars.MatchString(arg) {
+		return arg
+	}
+
+	arg = reCmdQuote.ReplaceAllString(arg, `$1$1\"`)
+	arg = reCmdTrailing.ReplaceAllString(arg, `$1$1`)
+	arg = reCmdExpansion.ReplaceAllString(arg, `^$0`)
+
+	return `"` + arg + `"`
+}
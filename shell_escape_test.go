@@ -0,0 +1,62 @@
+package lexec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatShellCommandForQuotesArgsPerShell(t *testing.T) {
+	command := []string{`echo`, `it's "quoted"`, `plain`}
+
+	assert.Equal(
+		t,
+		`echo "it's \"quoted\"" plain`,
+		FormatShellCommandFor(ShellSh, command),
+	)
+
+	assert.Equal(
+		t,
+		`echo 'it''s "quoted"' plain`,
+		FormatShellCommandFor(ShellPowerShell, command),
+	)
+
+	assert.Equal(
+		t,
+		`echo "it's \"quoted\"" plain`,
+		FormatShellCommandFor(ShellCmd, command),
+	)
+}
+
+func TestFormatShellCommandForCmdEscapesBackslashes(t *testing.T) {
+	assert.Equal(
+		t,
+		`dir "C:\Program Files\\" "a\\\"b" C:\x`,
+		FormatShellCommandFor(
+			ShellCmd,
+			[]string{`dir`, `C:\Program Files\`, `a\"b`, `C:\x`},
+		),
+	)
+}
+
+func TestFormatShellCommandForCmdEscapesVariableExpansion(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected string
+	}{
+		{`%PATH%`, `"^%PATH^%"`},
+		{`!PATH!`, `"^!PATH^!"`},
+		{`100% done`, `"100^% done"`},
+		{`wow!`, `"wow^!"`},
+		{`a\"%b%"`, `"a\\\"^%b^%\""`},
+	}
+
+	for _, test := range tests {
+		assert.Equal(
+			t,
+			`echo `+test.expected,
+			FormatShellCommandFor(ShellCmd, []string{`echo`, test.arg}),
+			test.arg,
+		)
+	}
+}
@@ -0,0 +1,38 @@
+package lexec
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// AllowSignals sets signals which are not considered an error when command is
+// terminated by them, e.g. SIGPIPE received by command which output has been
+// closed early by `head`. Wait returns nil for such commands and finish
+// event is logged as `signal: <name>`.
+//
+// Has no effect on Windows.
+func (execution *Execution) AllowSignals(signals ...syscall.Signal) *Execution {
+	execution.allowedSignals = append(execution.allowedSignals, signals...)
+
+	return execution
+}
+
+func (execution *Execution) getAllowedSignal(err error) (syscall.Signal, bool) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, false
+	}
+
+	signal, ok := getExitSignal(exitErr)
+	if !ok {
+		return 0, false
+	}
+
+	for _, allowed := range execution.allowedSignals {
+		if allowed == signal {
+			return signal, true
+		}
+	}
+
+	return 0, false
+}
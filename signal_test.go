@@ -0,0 +1,36 @@
+package lexec
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowSignalsIgnoresTerminationByAllowedSignal(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(Loggerf(logger), exec.Command(`sh`, `-c`, `kill -PIPE $$`)).
+		AllowSignals(syscall.SIGPIPE).
+		Run()
+	assert.NoError(t, err)
+	assert.Contains(t, log[len(log)-1], `-> signal: broken pipe`)
+}
+
+func TestAllowSignalsReportsOtherSignals(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `kill -TERM $$`)).
+		AllowSignals(syscall.SIGPIPE).
+		Run()
+	assert.True(t, IsExitStatus(err))
+}
+
+func TestAllowSignalsSigpipeIsErrorByDefault(t *testing.T) {
+	err := NewExec(nil, exec.Command(`sh`, `-c`, `kill -PIPE $$`)).Run()
+	assert.True(t, IsExitStatus(err))
+}
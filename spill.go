@@ -0,0 +1,201 @@
+package lexec
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/reconquest/karma-go"
+)
+
+// SpillToDiskAfter makes stdout and stderr to be captured into SpillBuffer
+// with given threshold, so output exceeding threshold is stored in temporary
+// file instead of memory. Temporary files are removed from disk by Wait, but
+// spilled output stays readable via GetStdout and GetStderr until Close is
+// called. Output closes them after reading.
+//
+// Chunks returned by GetStreamsData are kept in memory only up to threshold
+// bytes of combined output, further output is available only via GetStdout
+// and GetStderr. Everything built on these chunks, like output included into
+// error returned by Wait, OutputCombined, RunFull, OutputLines or
+// WaitForLine, sees only retained part of output, which is reported by
+// WasOutputTruncated, by Truncated field of ExitStatusError and by `output
+// truncated` field of the error.
+func (execution *Execution) SpillToDiskAfter(threshold int64) *Execution {
+	execution.mustNotBeStarted(`SpillToDiskAfter`)
+
+	execution.spillThreshold = threshold
+	execution.stdout = NewSpillBuffer(threshold)
+	execution.stderr = NewSpillBuffer(threshold)
+
+	return execution
+}
+
+// WasOutputTruncated returns true if chunks returned by GetStreamsData do not
+// hold all output of the command, because it exceeded threshold set via
+// SpillToDiskAfter.
+func (execution *Execution) WasOutputTruncated() bool {
+	execution.combinedMutex.Lock()
+	defer execution.combinedMutex.Unlock()
+
+	return execution.streamsTruncated
+}
+
+// removeSpillFiles removes temporary files from disk, keeping them open, so
+// spilled output can be read until Close. Files which can't be removed while
+// open, e.g. on Windows, are removed by Close.
+func (execution *Execution) removeSpillFiles() {
+	for _, writer := range []io.ReadWriter{execution.stdout, execution.stderr} {
+		if buffer, ok := writer.(*SpillBuffer); ok {
+			buffer.remove()
+		}
+	}
+}
+
+func (execution *Execution) closeSpillBuffers() error {
+	var result error
+
+	for _, writer := range []io.ReadWriter{execution.stdout, execution.stderr} {
+		if buffer, ok := writer.(*SpillBuffer); ok {
+			err := buffer.Close()
+			if err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+
+	return result
+}
+
+// SpillBuffer is CaptureBuffer which keeps data in memory until its size
+// exceeds threshold, all further data is written into temporary file.
+// Reading returns data from memory followed by data from temporary file.
+type SpillBuffer struct {
+	threshold int64
+	head      int64
+	memory    bytes.Buffer
+	file      *os.File
+	offset    int64
+	removed   bool
+}
+
+// NewSpillBuffer creates new SpillBuffer which keeps up to threshold bytes in
+// memory.
+func NewSpillBuffer(threshold int64) *SpillBuffer {
+	return &SpillBuffer{threshold: threshold}
+}
+
+// Write writes data into memory or into temporary file if threshold is
+// exceeded.
+func (buffer *SpillBuffer) Write(data []byte) (int, error) {
+	size := len(data)
+
+	if buffer.file == nil {
+		free := buffer.threshold - buffer.head
+		if free >= int64(len(data)) {
+			buffer.head += int64(len(data))
+
+			return buffer.memory.Write(data)
+		}
+
+		if free > 0 {
+			buffer.head += free
+
+			_, _ = buffer.memory.Write(data[:free])
+
+			data = data[free:]
+		}
+
+		file, err := ioutil.TempFile("", "lexec-spill-")
+		if err != nil {
+			return size - len(data), karma.Format(
+				err,
+				`can't create temporary file to spill output`,
+			)
+		}
+
+		buffer.file = file
+	}
+
+	written, err := buffer.file.Write(data)
+	if err != nil {
+		return size - len(data) + written, karma.Describe(
+			"path",
+			buffer.file.Name(),
+		).Format(
+			err,
+			`can't spill output to temporary file`,
+		)
+	}
+
+	return size, nil
+}
+
+// Read reads data from memory and then from temporary file.
+func (buffer *SpillBuffer) Read(data []byte) (int, error) {
+	if buffer.memory.Len() > 0 {
+		return buffer.memory.Read(data)
+	}
+
+	if buffer.file == nil {
+		return 0, io.EOF
+	}
+
+	read, err := buffer.file.ReadAt(data, buffer.offset)
+	buffer.offset += int64(read)
+
+	if err == io.EOF && read > 0 {
+		return read, nil
+	}
+
+	return read, err
+}
+
+// Path returns path to temporary file or empty string if threshold has not
+// been exceeded.
+func (buffer *SpillBuffer) Path() string {
+	if buffer.file == nil {
+		return ""
+	}
+
+	return buffer.file.Name()
+}
+
+// remove removes temporary file from disk, but keeps it open for reading.
+func (buffer *SpillBuffer) remove() {
+	if buffer.file == nil || buffer.removed {
+		return
+	}
+
+	if os.Remove(buffer.file.Name()) == nil {
+		buffer.removed = true
+	}
+}
+
+// Close closes and removes temporary file.
+func (buffer *SpillBuffer) Close() error {
+	if buffer.file == nil {
+		return nil
+	}
+
+	path := buffer.file.Name()
+
+	_ = buffer.file.Close()
+
+	buffer.file = nil
+
+	if buffer.removed {
+		return nil
+	}
+
+	err := os.Remove(path)
+	if err != nil {
+		return karma.Describe("path", path).Format(
+			err,
+			`can't remove temporary file with spilled output`,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package lexec
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpillToDiskAfterKeepsCompleteOutput(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `head -c 1048576 /dev/zero | tr '\0' a`),
+	).SpillToDiskAfter(1024)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	buffer, ok := execution.GetStdout().(*SpillBuffer)
+	assert.True(t, ok)
+
+	path := buffer.Path()
+	assert.NotEmpty(t, path)
+	assert.NoFileExists(t, path)
+
+	stdout, err := ioutil.ReadAll(execution.GetStdout())
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{'a'}, 1048576), stdout)
+
+	var retained int
+	for _, data := range execution.GetStreamsData() {
+		retained += len(data.Data)
+	}
+
+	assert.Equal(t, 1024, retained)
+	assert.True(t, execution.WasOutputTruncated())
+
+	err = execution.Close()
+	assert.NoError(t, err)
+}
+
+func TestSpillToDiskAfterReportsTruncatedOutputInError(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `head -c 4096 /dev/zero | tr '\0' a; exit 1`),
+	).SpillToDiskAfter(1024)
+
+	err := execution.Run()
+	assert.True(t, IsExitStatus(err))
+	assert.Contains(t, err.Error(), `output truncated`)
+
+	var exitErr ExitStatusError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.True(t, exitErr.Truncated)
+	assert.Len(t, exitErr.Stdout, 1024)
+
+	assert.NoError(t, execution.Close())
+}
+
+func TestSpillToDiskAfterDoesNotReportTruncationOfSmallOutput(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`echo`, `1`)).
+		SpillToDiskAfter(1024)
+
+	assert.NoError(t, execution.Run())
+	assert.False(t, execution.WasOutputTruncated())
+}
+
+func TestOutputRemovesSpilledOutputFiles(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `head -c 4096 /dev/zero | tr '\0' a`),
+	).SpillToDiskAfter(1024)
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Len(t, stdout, 4096)
+
+	buffer := execution.GetStdout().(*SpillBuffer)
+	assert.Empty(t, buffer.Path())
+}
+
+func TestSpillBufferKeepsSmallOutputInMemory(t *testing.T) {
+	buffer := NewSpillBuffer(8)
+
+	_, _ = buffer.Write([]byte(`1234`))
+	_, _ = buffer.Write([]byte(`5678`))
+	assert.Empty(t, buffer.Path())
+
+	_, _ = buffer.Write([]byte(`9`))
+	assert.NotEmpty(t, buffer.Path())
+
+	data, err := ioutil.ReadAll(buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, `123456789`, string(data))
+
+	assert.NoError(t, buffer.Close())
+}
@@ -0,0 +1,112 @@
+package lexec
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/reconquest/karma-go"
+)
+
+// FailOnStderr makes Wait return error if command has written anything to
+// stderr, even if command exited with zero exit code. Finish is logged as
+// `exit 0` anyway.
+//
+// Stderr is checked only when it is read by execution, so FailOnStderr has
+// no effect if stderr is read by caller via StderrPipe, written directly into
+// file via InheritStderr or merged with stdout via SetSingleWriterOrdering.
+func (execution *Execution) FailOnStderr() *Execution {
+	execution.failOnStderr = true
+
+	return execution
+}
+
+// IgnoreStderrMatching excludes stderr lines matching given regexp from
+// FailOnStderr check, so harmless warnings will not fail execution.
+func (execution *Execution) IgnoreStderrMatching(
+	re *regexp.Regexp,
+) *Execution {
+	execution.stderrIgnores = append(execution.stderrIgnores, re)
+
+	return execution
+}
+
+// LogIgnoredStderrSeparately makes stderr lines matching IgnoreStderrMatching
+// to be logged as IgnoredStderr stream instead of Stderr, so they can be
+// logged with lower level, e.g. LoggerSlog logs them with Info level instead
+// of Warn. Captured output is not affected.
+func (execution *Execution) LogIgnoredStderrSeparately(
+	enabled bool,
+) *Execution {
+	execution.logIgnoredStderr = enabled
+
+	return execution
+}
+
+func (execution *Execution) isStderrIgnored(line string) bool {
+	for _, re := range execution.stderrIgnores {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (execution *Execution) checkStderrLine(line string) {
+	if !execution.failOnStderr || line == "" {
+		return
+	}
+
+	if execution.isStderrIgnored(line) {
+		return
+	}
+
+	execution.stderrFailures = append(execution.stderrFailures, line)
+}
+
+// logStderr logs stderr lines as Stderr or IgnoredStderr stream, keeping
+// consecutive lines of same stream together. Should be called with logMutex
+// held.
+func (execution *Execution) logStderr(lines []byte) {
+	var (
+		batch  [][]byte
+		stream Stream
+	)
+
+	flush := func() {
+		if len(batch) > 0 {
+			execution.logOutput(stream, bytes.Join(batch, []byte("\n")))
+		}
+
+		batch = nil
+	}
+
+	for _, line := range bytes.Split(lines, []byte("\n")) {
+		target := Stderr
+		if execution.isStderrIgnored(string(line)) {
+			target = IgnoredStderr
+		}
+
+		if target != stream {
+			flush()
+
+			stream = target
+		}
+
+		batch = append(batch, line)
+	}
+
+	flush()
+}
+
+func (execution *Execution) getStderrError() error {
+	if len(execution.stderrFailures) == 0 {
+		return nil
+	}
+
+	return karma.Describe("command", execution.String()).Format(
+		strings.Join(execution.stderrFailures, "\n"),
+		`execution completed with output on stderr`,
+	)
+}
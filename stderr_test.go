@@ -0,0 +1,80 @@
+package lexec
+
+import (
+	"os/exec"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailOnStderrIgnoresMatchingLines(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 'warning: deprecated' >&2`),
+	).
+		FailOnStderr().
+		IgnoreStderrMatching(regexp.MustCompile(`^warning: `)).
+		Run()
+	assert.NoError(t, err)
+}
+
+func TestFailOnStderrFailsOnNotIgnoredLines(t *testing.T) {
+	err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 'warning: deprecated' >&2; echo 'error: broken' >&2`,
+		),
+	).
+		FailOnStderr().
+		IgnoreStderrMatching(regexp.MustCompile(`^warning: `)).
+		Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `output on stderr`)
+	assert.Contains(t, err.Error(), `error: broken`)
+	assert.NotContains(t, err.Error(), `─ warning: deprecated`)
+}
+
+func TestFailOnStderrLogsFinish(t *testing.T) {
+	var finish []string
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Finish {
+			finish = append(finish, string(data))
+		}
+	}
+
+	err := NewExec(logger, exec.Command(`sh`, `-c`, `echo 'error' >&2`)).
+		FailOnStderr().
+		Run()
+	assert.Error(t, err)
+	assert.Equal(t, []string{`exit 0`}, finish)
+}
+
+func TestLogIgnoredStderrSeparatelyLogsIgnoredLinesAsSeparateStream(t *testing.T) {
+	var logged []string
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Stderr || stream == IgnoredStderr {
+			logged = append(logged, string(stream)+`: `+string(data))
+		}
+	}
+
+	err := NewExec(
+		logger,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 'warning: deprecated' >&2; echo 'error: broken' >&2`,
+		),
+	).
+		IgnoreStderrMatching(regexp.MustCompile(`^warning: `)).
+		LogIgnoredStderrSeparately(true).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`ignored: warning: deprecated`,
+		`stderr: error: broken`,
+	}, logged)
+}
@@ -0,0 +1,348 @@
+package lexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/reconquest/karma-go"
+)
+
+var errStdinWriteTimeout = errors.New(`stdin write timed out`)
+
+// SetStdinWriteTimeout sets maximum duration of single write into command
+// stdin when stdin is set via SetStdin.
+//
+// If command stops reading stdin and write stalls longer than given timeout,
+// stdin will be closed and timeout will be logged, so Wait will not hang
+// forever.
+func (execution *Execution) SetStdinWriteTimeout(
+	timeout time.Duration,
+) *Execution {
+	execution.mustNotBeStarted(`SetStdinWriteTimeout`)
+
+	execution.stdinWriteTimeout = timeout
+
+	return execution
+}
+
+// SetStdinContext sets context which aborts copying of reader set via
+// SetStdin into command stdin. When context is done, copying stops
+// promptly, stdin is closed and context error is returned by Wait.
+//
+// Command itself is not killed, it only observes closed stdin.
+func (execution *Execution) SetStdinContext(
+	ctx context.Context,
+) *Execution {
+	execution.mustNotBeStarted(`SetStdinContext`)
+
+	execution.stdinContext = ctx
+
+	return execution
+}
+
+// SetStdinFromFile sets file which will be passed to the command as stdin
+// directly, so command inherits its file descriptor and no copying through
+// Go pipe is done. It is useful for zero-copy pipelines, e.g. when file is
+// read end of os.Pipe connected to another command output.
+//
+// LogStdin, SetStdinWriteTimeout, SetStdinContext and recording of stdin
+// have no effect in this mode. File is not closed by execution.
+func (execution *Execution) SetStdinFromFile(file *os.File) *Execution {
+	execution.mustNotBeStarted(`SetStdinFromFile`)
+
+	execution.stdinOSFile = file
+	execution.hasStdinFd = false
+
+	return execution
+}
+
+// SetStdinFd is same as SetStdinFromFile, but accepts raw file descriptor.
+//
+// Descriptor is duplicated by Start and only duplicate is closed after Wait,
+// so given descriptor stays open and is still owned by caller.
+func (execution *Execution) SetStdinFd(fd uintptr) *Execution {
+	execution.mustNotBeStarted(`SetStdinFd`)
+
+	execution.stdinOSFile = nil
+	execution.stdinFd = fd
+	execution.hasStdinFd = true
+
+	return execution
+}
+
+func (execution *Execution) setupStdinFd() error {
+	if !execution.hasStdinFd {
+		return nil
+	}
+
+	fd, err := dupFd(execution.stdinFd)
+	if err != nil {
+		return karma.Describe("fd", execution.stdinFd).Format(
+			err,
+			`can't duplicate stdin file descriptor for command: %s`,
+			execution.String(),
+		)
+	}
+
+	file := os.NewFile(fd, "stdin")
+
+	execution.files = append(execution.files, file)
+	execution.stdinOSFile = file
+
+	return nil
+}
+
+// LogStdin enables logging of data passed to the command stdin as Stdin
+// stream events. Every chunk read by the command from reader set via SetStdin
+// or written into writer returned by GetStdin is logged.
+func (execution *Execution) LogStdin() *Execution {
+	execution.mustNotBeStarted(`LogStdin`)
+
+	execution.logStdin = true
+
+	return execution
+}
+
+// watchStdin reports whether data passed to command stdin should be
+// intercepted, either for logging or for recording.
+func (execution *Execution) watchStdin() bool {
+	return execution.logStdin || execution.recorder != nil
+}
+
+func (execution *Execution) handleStdinChunk(data []byte) {
+	if execution.recorder != nil {
+		execution.recordStdin(data)
+	}
+
+	if execution.logStdin {
+		execution.log(Stdin, bytes.TrimRight(data, "\n"))
+	}
+}
+
+// getStdinSource returns reader set via SetStdin, wrapped to log or record
+// every read chunk if LogStdin or SetRecorder is enabled.
+func (execution *Execution) getStdinSource() io.Reader {
+	if !execution.watchStdin() {
+		return execution.stdin
+	}
+
+	return &stdinLogReader{
+		reader: execution.stdin,
+		log:    execution.handleStdinChunk,
+	}
+}
+
+type stdinLogReader struct {
+	reader io.Reader
+	log    func([]byte)
+}
+
+func (reader *stdinLogReader) Read(data []byte) (int, error) {
+	size, err := reader.reader.Read(data)
+	if size > 0 {
+		reader.log(data[:size])
+	}
+
+	return size, err
+}
+
+type stdinLogWriter struct {
+	io.WriteCloser
+	log func([]byte)
+}
+
+func (writer *stdinLogWriter) Write(data []byte) (int, error) {
+	size, err := writer.WriteCloser.Write(data)
+	if size > 0 {
+		writer.log(data[:size])
+	}
+
+	return size, err
+}
+
+// SetStdinFromFunc sets function which generates command stdin. Function is
+// run in separate goroutine after command is started and stdin is closed when
+// function returns. Error returned by function is returned by Wait.
+func (execution *Execution) SetStdinFromFunc(
+	generate func(io.Writer) error,
+) *Execution {
+	execution.mustNotBeStarted(`SetStdinFromFunc`)
+
+	execution.stdinFunc = generate
+
+	return execution
+}
+
+func (execution *Execution) setupStdinFunc() error {
+	pipe, err := execution.command.StdinPipe()
+	if err != nil {
+		return karma.Format(
+			err,
+			`can't get stdin pipe from command: %s`,
+			execution,
+		)
+	}
+
+	if execution.watchStdin() {
+		pipe = &stdinLogWriter{
+			WriteCloser: pipe,
+			log:         execution.handleStdinChunk,
+		}
+	}
+
+	execution.stdinCopy = func() error {
+		err := execution.stdinFunc(pipe)
+
+		closeErr := pipe.Close()
+		if err != nil {
+			return err
+		}
+
+		return closeErr
+	}
+
+	return nil
+}
+
+func (execution *Execution) setupStdinCopy() error {
+	pipe, err := execution.command.StdinPipe()
+	if err != nil {
+		return karma.Format(
+			err,
+			`can't get stdin pipe from command: %s`,
+			execution,
+		)
+	}
+
+	source := execution.getStdinSource()
+
+	ctx := execution.stdinContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	execution.stdinCopy = func() error {
+		return copyWithWriteTimeout(
+			ctx,
+			execution.clock,
+			pipe,
+			source,
+			execution.stdinWriteTimeout,
+		)
+	}
+
+	return nil
+}
+
+func (execution *Execution) startStdinCopy() {
+	if execution.stdinCopy == nil {
+		return
+	}
+
+	execution.stdinCopyDone = make(chan error, 1)
+
+	go func() {
+		execution.stdinCopyDone <- execution.stdinCopy()
+	}()
+}
+
+func (execution *Execution) waitStdinCopy() error {
+	if execution.stdinCopyDone == nil {
+		return nil
+	}
+
+	err := <-execution.stdinCopyDone
+
+	switch {
+	case err == nil:
+		return nil
+
+	case err == errStdinWriteTimeout:
+		execution.log(Stdin, []byte(fmt.Sprintf(
+			`write timed out after %s, stdin closed`,
+			execution.stdinWriteTimeout,
+		)))
+
+		return nil
+
+	case errors.Is(err, syscall.EPIPE), errors.Is(err, os.ErrClosed):
+		return nil
+
+	default:
+		return karma.Format(
+			err,
+			`can't write command stdin: %s`,
+			execution.String(),
+		)
+	}
+}
+
+// copyWithWriteTimeout copies reader into writer until EOF. Writer is closed
+// when single write takes longer than given timeout (zero means no timeout)
+// or when context is done, which unblocks stalled write.
+func copyWithWriteTimeout(
+	ctx context.Context,
+	clock clock,
+	writer io.WriteCloser,
+	reader io.Reader,
+	timeout time.Duration,
+) error {
+	defer writer.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = writer.Close()
+		case <-done:
+		}
+	}()
+
+	buffer := make([]byte, 32*1024)
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		size, err := reader.Read(buffer)
+		if size > 0 {
+			var timer clockTimer
+			if timeout > 0 {
+				timer = clock.AfterFunc(timeout, func() {
+					_ = writer.Close()
+				})
+			}
+
+			_, writeErr := writer.Write(buffer[:size])
+
+			if timer != nil && !timer.Stop() {
+				return errStdinWriteTimeout
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
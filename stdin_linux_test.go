@@ -0,0 +1,65 @@
+package lexec
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStdinFdPassesFileDescriptorToCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `stdin`)
+
+	err := ioutil.WriteFile(path, []byte("hello\n"), 0644)
+	assert.NoError(t, err)
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	stdout, _, err := NewExec(nil, exec.Command(`readlink`, `/proc/self/fd/0`)).
+		SetStdinFd(file.Fd()).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, path, strings.TrimSpace(string(stdout)))
+
+	_, err = file.Seek(0, 0)
+	assert.NoError(t, err)
+
+	stdout, _, err = NewExec(nil, exec.Command(`cat`)).
+		SetStdinFromFile(file).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(stdout))
+}
+
+func TestSetStdinFdDoesNotCloseFileDescriptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `stdin`)
+
+	err := ioutil.WriteFile(path, []byte("hello\n"), 0644)
+	assert.NoError(t, err)
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	stdout, _, err := NewExec(nil, exec.Command(`cat`)).
+		SetStdinFd(file.Fd()).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(stdout))
+
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = file.Seek(0, 0)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,198 @@
+package lexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdinWriteTimeoutIgnoresStdinClosedByCommand(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	execution := NewExec(
+		Loggerf(logger),
+		exec.Command(`sh`, `-c`, `exec 0<&-; sleep 0.3`),
+	).
+		SetStdin(bytes.NewReader(make([]byte, 1024*1024))).
+		SetStdinWriteTimeout(50 * time.Millisecond)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | sh -c "exec 0<&-; sleep 0.3"`,
+		`finish | sh -c "exec 0<&-; sleep 0.3" -> exit 0`,
+	}, log)
+}
+
+func TestStdinWriteTimeoutLogsStalledWrite(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	execution := NewExec(
+		Loggerf(logger),
+		exec.Command(`sleep`, `0.3`),
+	).
+		SetStdin(bytes.NewReader(make([]byte, 1024*1024))).
+		SetStdinWriteTimeout(50 * time.Millisecond)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | sleep 0.3`,
+		`stdin  |  write timed out after 50ms, stdin closed`,
+		`finish | sleep 0.3 -> exit 0`,
+	}, log)
+}
+
+func TestStdinWriteTimeoutDoesNotWaitForStdinHolders(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`exec 3<&0; sleep 1 <&3 >/dev/null 2>&1 &`,
+		),
+	).
+		SetStdin(bytes.NewReader(make([]byte, 1024*1024))).
+		SetStdinWriteTimeout(50 * time.Millisecond)
+
+	started := time.Now()
+
+	err := execution.Run()
+	assert.NoError(t, err)
+	assert.True(t, time.Since(started) < 500*time.Millisecond)
+}
+
+func TestLogStdinLogsEveryChunkReadFromReader(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.Write([]byte("first\n"))
+		writer.Write([]byte("second\n"))
+		writer.Close()
+	}()
+
+	execution := NewExec(Loggerf(logger), exec.Command(`cat`)).
+		NoStdLog().
+		SetStdin(reader).
+		LogStdin()
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | cat`,
+		`stdin  |  first`,
+		`stdin  |  second`,
+		`finish | cat -> exit 0`,
+	}, log)
+}
+
+func TestLogStdinLogsWritesIntoStdinPipe(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	execution := NewExec(Loggerf(logger), exec.Command(`cat`)).
+		NoStdLog().
+		LogStdin()
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	_, err = execution.GetStdin().Write([]byte("data\n"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, execution.GetStdin().Close())
+	assert.NoError(t, execution.Wait())
+
+	assert.Equal(t, []string{
+		`launch | cat`,
+		`stdin  |  data`,
+		`finish | cat -> exit 0`,
+	}, log)
+}
+
+func TestStdinFromFuncGeneratesStdin(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`wc`, `-l`)).
+		SetStdinFromFunc(func(writer io.Writer) error {
+			for i := 0; i < 1000; i++ {
+				_, err := fmt.Fprintf(writer, "line %d\n", i)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, `1000`, strings.TrimSpace(string(stdout)))
+}
+
+func TestStdinFromFuncErrorIsReturnedByWait(t *testing.T) {
+	err := NewExec(nil, exec.Command(`cat`)).
+		SetStdinFromFunc(func(writer io.Writer) error {
+			return errors.New(`generator failed`)
+		}).
+		Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `generator failed`)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(data []byte) (int, error) {
+	for i := range data {
+		data[i] = 0
+	}
+
+	return len(data), nil
+}
+
+func TestStdinContextAbortsStdinCopy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		100*time.Millisecond,
+	)
+	defer cancel()
+
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `cat > /dev/null`),
+	).
+		SetStdin(zeroReader{}).
+		SetStdinContext(ctx)
+
+	startedAt := time.Now()
+
+	err := execution.Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.Less(t, time.Since(startedAt), 2*time.Second)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"syscall"
+)
+
+func dupFd(fd uintptr) (uintptr, error) {
+	dup, err := syscall.Dup(int(fd))
+	if err != nil {
+		return 0, err
+	}
+
+	syscall.CloseOnExec(dup)
+
+	return uintptr(dup), nil
+}
@@ -0,0 +1,29 @@
+package lexec
+
+import (
+	"syscall"
+)
+
+func dupFd(fd uintptr) (uintptr, error) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, err
+	}
+
+	var dup syscall.Handle
+
+	err = syscall.DuplicateHandle(
+		process,
+		syscall.Handle(fd),
+		process,
+		&dup,
+		0,
+		false,
+		syscall.DUPLICATE_SAME_ACCESS,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return uintptr(dup), nil
+}
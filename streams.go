@@ -1,8 +1,13 @@
 package lexec
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"strings"
 	"sync"
+
+	"golang.org/x/text/encoding"
 )
 
 // Stream represents execution output stream.
@@ -15,6 +20,17 @@ const (
 	// Stdout is ID for execution stderr.
 	Stderr Stream = `stderr`
 
+	// IgnoredStderr is ID for execution stderr lines matching
+	// IgnoreStderrMatching, which are logged separately if
+	// LogIgnoredStderrSeparately is enabled.
+	IgnoredStderr Stream = `ignored`
+
+	// Stdin is ID for execution stdin.
+	Stdin Stream = `stdin`
+
+	// Env is ID for execution environment, which is logged if LogEnv is set.
+	Env Stream = `env`
+
 	// Start is ID for execution start.
 	Launch Stream = `launch`
 
@@ -26,7 +42,8 @@ const (
 type StreamData struct {
 	Stream Stream
 
-	// Data represents output that has been written into given stream.
+	// Data represents output that has been written into given stream as
+	// single chunk read from the command output pipe.
 	Data []byte
 }
 
@@ -34,6 +51,14 @@ type streamWriter struct {
 	output *[]StreamData
 	stream Stream
 	mutex  *sync.Mutex
+	onData func(StreamData)
+
+	// retained counts bytes stored in output, which is shared between
+	// streams, no more than limit bytes are stored if limit is positive.
+	// truncated is set once some data is not stored.
+	retained  *int64
+	limit     int64
+	truncated *bool
 }
 
 func (writer *streamWriter) Write(data []byte) (int, error) {
@@ -43,22 +68,313 @@ func (writer *streamWriter) Write(data []byte) (int, error) {
 	indirected := make([]byte, len(data))
 	copy(indirected, data)
 
-	*writer.output = append(*writer.output, StreamData{
+	item := StreamData{
 		Stream: writer.stream,
 		Data:   indirected,
-	})
+	}
+
+	switch free := writer.limit - *writer.retained; {
+	case writer.limit <= 0 || free >= int64(len(indirected)):
+		*writer.output = append(*writer.output, item)
+		*writer.retained += int64(len(indirected))
+
+	case free > 0:
+		*writer.output = append(*writer.output, StreamData{
+			Stream: writer.stream,
+			Data:   indirected[:free:free],
+		})
+		*writer.retained += free
+		*writer.truncated = true
+
+	default:
+		*writer.truncated = true
+	}
+
+	if writer.onData != nil {
+		writer.onData(item)
+	}
 
 	return len(indirected), nil
 }
 
+// skipEmptyWriter does not pass zero-length writes to the underlying writer.
+type skipEmptyWriter struct {
+	writer io.Writer
+}
+
+func (writer skipEmptyWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	return writer.writer.Write(data)
+}
+
+// transformWriter passes every chunk through transform function before
+// writing it into the underlying writer.
+type transformWriter struct {
+	writer    io.Writer
+	stream    Stream
+	transform func(Stream, []byte) []byte
+}
+
+func (writer *transformWriter) Write(data []byte) (int, error) {
+	_, err := writer.writer.Write(writer.transform(writer.stream, data))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
 func newStreamWriter(
 	output *[]StreamData,
 	mutex *sync.Mutex,
 	stream Stream,
+	onData func(StreamData),
+	retained *int64,
+	limit int64,
+	truncated *bool,
 ) io.Writer {
 	return &streamWriter{
-		output: output,
-		stream: stream,
+		output:    output,
+		stream:    stream,
+		mutex:     mutex,
+		onData:    onData,
+		retained:  retained,
+		limit:     limit,
+		truncated: truncated,
+	}
+}
+
+// lockedWriter serializes writes into underlying writer, so same writer can
+// be safely used both for stdout and stderr.
+type lockedWriter struct {
+	writer io.Writer
+	mutex  *sync.Mutex
+}
+
+func (writer *lockedWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	return writer.writer.Write(data)
+}
+
+func newLockedWriter(writer io.Writer, mutex *sync.Mutex) io.Writer {
+	return &lockedWriter{
+		writer: writer,
 		mutex:  mutex,
 	}
 }
+
+// newlineNormalizer replaces CRLF with LF, keeping trailing CR of the chunk
+// until next write, since LF can arrive in the next chunk.
+type newlineNormalizer struct {
+	writer   io.Writer
+	carriage bool
+}
+
+func (normalizer *newlineNormalizer) Write(data []byte) (int, error) {
+	size := len(data)
+
+	if normalizer.carriage {
+		normalizer.carriage = false
+
+		if len(data) == 0 || data[0] != '\n' {
+			data = append([]byte{'\r'}, data...)
+		}
+	}
+
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		normalizer.carriage = true
+		data = data[:len(data)-1]
+	}
+
+	if len(data) > 0 {
+		_, err := normalizer.writer.Write(data)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// Flush writes pending CR, if any.
+func (normalizer *newlineNormalizer) Flush() error {
+	if !normalizer.carriage {
+		return nil
+	}
+
+	normalizer.carriage = false
+
+	_, err := normalizer.writer.Write([]byte{'\r'})
+
+	return err
+}
+
+func newNewlineNormalizer(writer io.Writer) *newlineNormalizer {
+	return &newlineNormalizer{
+		writer: writer,
+	}
+}
+
+// OnStreamData sets callback which is called for every chunk of output right
+// after it is appended to data returned by GetStreamsData. Callback is called
+// under same lock, so chunks are passed in the same order, but callback
+// should not call other methods of the execution. Callback receives every
+// chunk even if it is not retained in GetStreamsData due to SpillToDiskAfter.
+func (execution *Execution) OnStreamData(callback func(StreamData)) *Execution {
+	execution.onStreamData = callback
+
+	return execution
+}
+
+// SetStreamTransform sets function which is applied to every chunk of
+// stdout and stderr before it is written to the target writers, stored for
+// GetStreamsData and logged. Returned slice replaces the chunk, returning
+// empty slice drops it.
+//
+// Chunks are not aligned to lines, so transform should not rely on it.
+func (execution *Execution) SetStreamTransform(
+	transform func(stream Stream, chunk []byte) []byte,
+) *Execution {
+	execution.mustNotBeStarted(`SetStreamTransform`)
+
+	execution.streamTransform = transform
+
+	return execution
+}
+
+// SetOutputEncoding sets encoding of command stdout and stderr, which is
+// used to convert output into UTF-8 before it is written to the target
+// writers, stored and logged, e.g. charmap.ISO8859_1 for Latin-1 output of
+// legacy tools. By default output is passed as is.
+func (execution *Execution) SetOutputEncoding(
+	encoding encoding.Encoding,
+) *Execution {
+	execution.mustNotBeStarted(`SetOutputEncoding`)
+
+	execution.outputEncoding = encoding
+
+	return execution
+}
+
+// RangeStreams calls given function for every chunk of output as returned by
+// GetStreamsData, until function returns false. Chunks are iterated under
+// lock without copying, so it is safe to call it while command is running,
+// but function should not call other methods of the execution.
+func (execution *Execution) RangeStreams(fn func(StreamData) bool) {
+	execution.combinedMutex.Lock()
+	defer execution.combinedMutex.Unlock()
+
+	for _, data := range execution.combinedStreams {
+		if !fn(data) {
+			return
+		}
+	}
+}
+
+// FilterStreams returns chunks of output as returned by GetStreamsData for
+// which given predicate returns true, e.g. only stderr chunks. Chunks are
+// collected under lock, so it is safe to call it while command is running.
+func (execution *Execution) FilterStreams(
+	predicate func(StreamData) bool,
+) []StreamData {
+	var result []StreamData
+
+	execution.RangeStreams(func(data StreamData) bool {
+		if predicate(data) {
+			result = append(result, data)
+		}
+
+		return true
+	})
+
+	return result
+}
+
+// CombinedOutputReader returns reader over stdout and stderr output
+// interleaved in order of arrival, same as GetStreamsData. Captured chunks are
+// read as is without being joined into single buffer. Should be called after
+// Wait.
+func (execution *Execution) CombinedOutputReader() io.Reader {
+	execution.combinedMutex.Lock()
+	defer execution.combinedMutex.Unlock()
+
+	readers := make([]io.Reader, len(execution.combinedStreams))
+	for i, data := range execution.combinedStreams {
+		readers[i] = bytes.NewReader(data.Data)
+	}
+
+	return io.MultiReader(readers...)
+}
+
+// StreamsString renders output returned by GetStreamsData as stable
+// multi-line string, where each line is prefixed with stream name, like
+// `[stdout] line`. Lines split across several chunks are joined, so result
+// does not depend on chunking. Useful for snapshot testing.
+func (execution *Execution) StreamsString() string {
+	var lines []string
+
+	execution.rangeLines(func(stream Stream, line []byte) {
+		lines = append(lines, fmt.Sprintf("[%s] %s", stream, line))
+	})
+
+	return strings.Join(lines, "\n")
+}
+
+// OutputLines returns stdout and stderr output interleaved in order of
+// arrival and split into lines same way as for the logger: lines split
+// across several chunks are joined and trailing newline does not produce
+// empty line. Should be called after Wait.
+func (execution *Execution) OutputLines() []string {
+	lines := []string{}
+
+	execution.rangeLines(func(_ Stream, line []byte) {
+		lines = append(lines, string(line))
+	})
+
+	return lines
+}
+
+// rangeLines calls given function for every line of captured output in order
+// of line completion. Incomplete last lines of every stream are passed last.
+func (execution *Execution) rangeLines(fn func(stream Stream, line []byte)) {
+	var (
+		pending = map[Stream][]byte{}
+		order   []Stream
+	)
+
+	execution.combinedMutex.Lock()
+	for _, data := range execution.combinedStreams {
+		if _, ok := pending[data.Stream]; !ok {
+			order = append(order, data.Stream)
+		}
+
+		buffer := append(pending[data.Stream], data.Data...)
+
+		for {
+			index := bytes.IndexByte(buffer, '\n')
+			if index < 0 {
+				break
+			}
+
+			fn(data.Stream, buffer[:index])
+
+			buffer = buffer[index+1:]
+		}
+
+		pending[data.Stream] = buffer
+	}
+	execution.combinedMutex.Unlock()
+
+	for _, stream := range order {
+		if len(pending[stream]) > 0 {
+			fn(stream, pending[stream])
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package lexec
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleWriterOrderingWithStreamTransformUsesSinglePipe(t *testing.T) {
+	stdout, _, err := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`readlink /proc/self/fd/1; readlink /proc/self/fd/2 >&2`,
+		),
+	).
+		SetSingleWriterOrdering(true).
+		SetStreamTransform(func(stream Stream, chunk []byte) []byte {
+			return chunk
+		}).
+		Output()
+	assert.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(stdout), []byte("\n"))
+	assert.Len(t, lines, 2)
+	assert.Equal(t, string(lines[0]), string(lines[1]))
+}
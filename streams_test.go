@@ -0,0 +1,336 @@
+package lexec
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// chunkedCommand is Command which writes given chunks into their streams one
+// by one, so chunk boundaries and order do not depend on timing.
+type chunkedCommand struct {
+	*replayCommand
+	chunks []StreamData
+}
+
+func (command *chunkedCommand) Start() error {
+	for _, chunk := range command.chunks {
+		target := command.stdout
+		if chunk.Stream == Stderr {
+			target = command.stderr
+		}
+
+		_, err := target.Write(chunk.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newChunkedCommand(chunks ...StreamData) *chunkedCommand {
+	replay := NewReplay(Recording{Args: []string{`chunks`}})
+
+	return &chunkedCommand{
+		replayCommand: replay.(*replayCommand),
+		chunks:        chunks,
+	}
+}
+
+func TestStreamsDataPreservesChunkBoundaries(t *testing.T) {
+	chunks := []StreamData{
+		{Stream: Stdout, Data: []byte("aaaa")},
+		{Stream: Stdout, Data: []byte("bb")},
+		{Stream: Stdout, Data: []byte("c\nd")},
+	}
+
+	execution := New(nil, newChunkedCommand(chunks...))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, chunks, execution.GetStreamsData())
+}
+
+func TestSameWriterCanBeUsedForStdoutAndStderr(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`for i in 1 2 3 4 5; do echo out; echo err >&2; done`,
+		),
+	).
+		SetStdout(output).
+		SetStderr(output)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 5, strings.Count(output.String(), "out\n"))
+	assert.Equal(t, 5, strings.Count(output.String(), "err\n"))
+}
+
+func TestNormalizeNewlinesConvertsCapturedCRLF(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `printf 'a\r\nb\r'; sleep 0.1; printf '\nc\r'`),
+	).NormalizeNewlines(true)
+
+	stdout, _, err := execution.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\r", string(stdout))
+
+	var combined []byte
+	for _, data := range execution.GetStreamsData() {
+		combined = append(combined, data.Data...)
+	}
+
+	assert.Equal(t, "a\nb\nc\r", string(combined))
+}
+
+func TestSingleWriterOrderingPreservesWriteOrder(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		execution := NewExec(
+			nil,
+			exec.Command(`sh`, `-c`, `echo 1; echo 2 >&2; echo 3; echo 4 >&2`),
+		).SetSingleWriterOrdering(true)
+
+		err := execution.Run()
+		assert.NoError(t, err)
+
+		var combined []byte
+		for _, data := range execution.GetStreamsData() {
+			assert.Equal(t, Stdout, data.Stream)
+
+			combined = append(combined, data.Data...)
+		}
+
+		assert.Equal(t, "1\n2\n3\n4\n", string(combined))
+	}
+}
+
+func TestStreamsStringRendersMixedOutput(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 1; printf 2 >&2; echo 3 >&2; printf 4`,
+		),
+	)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	lines := strings.Split(execution.StreamsString(), "\n")
+
+	// streams are read concurrently, so only incomplete last line is known to
+	// be rendered last
+	assert.Len(t, lines, 3)
+	assert.ElementsMatch(t, []string{"[stdout] 1", "[stderr] 23"}, lines[:2])
+	assert.Equal(t, "[stdout] 4", lines[2])
+}
+
+func TestRangeStreamsStopsWhenCallbackReturnsFalse(t *testing.T) {
+	execution := New(nil, newChunkedCommand(
+		StreamData{Stream: Stdout, Data: []byte("1\n")},
+		StreamData{Stream: Stderr, Data: []byte("2\n")},
+		StreamData{Stream: Stdout, Data: []byte("3\n")},
+	))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	visited := []StreamData{}
+
+	execution.RangeStreams(func(data StreamData) bool {
+		visited = append(visited, data)
+
+		return data.Stream != Stderr
+	})
+
+	assert.Equal(t, []StreamData{
+		{Stream: Stdout, Data: []byte("1\n")},
+		{Stream: Stderr, Data: []byte("2\n")},
+	}, visited)
+}
+
+func TestEmptyWritesAreSkipped(t *testing.T) {
+	recording := Recording{
+		Args:   []string{`replay`},
+		Stderr: []byte("error\n"),
+	}
+
+	execution := New(nil, NewReplay(recording))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamData{
+		{Stream: Stderr, Data: []byte("error\n")},
+	}, execution.GetStreamsData())
+
+	execution = New(nil, NewReplay(recording)).SetSkipEmptyWrites(false)
+
+	err = execution.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []StreamData{
+		{Stream: Stdout, Data: []byte{}},
+		{Stream: Stderr, Data: []byte("error\n")},
+	}, execution.GetStreamsData())
+}
+
+func TestCombinedOutputReaderReadsInterleavedOutput(t *testing.T) {
+	execution := New(nil, newChunkedCommand(
+		StreamData{Stream: Stdout, Data: []byte("1\n")},
+		StreamData{Stream: Stderr, Data: []byte("2\n")},
+		StreamData{Stream: Stdout, Data: []byte("3\n")},
+	))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	var lines []string
+
+	scanner := bufio.NewScanner(execution.CombinedOutputReader())
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, []string{`1`, `2`, `3`}, lines)
+}
+
+func TestOnStreamDataReceivesEveryChunk(t *testing.T) {
+	var received []StreamData
+
+	execution := New(nil, newChunkedCommand(
+		StreamData{Stream: Stdout, Data: []byte("1\n")},
+		StreamData{Stream: Stderr, Data: []byte("2\n")},
+		StreamData{Stream: Stdout, Data: []byte("3")},
+	)).OnStreamData(func(data StreamData) {
+		received = append(received, data)
+	})
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []StreamData{
+		{Stream: Stdout, Data: []byte("1\n")},
+		{Stream: Stderr, Data: []byte("2\n")},
+		{Stream: Stdout, Data: []byte("3")},
+	}, received)
+	assert.Equal(t, execution.GetStreamsData(), received)
+}
+
+func TestOutputLinesSplitsCombinedOutput(t *testing.T) {
+	execution := New(nil, newChunkedCommand(
+		StreamData{Stream: Stdout, Data: []byte("1\n2\n")},
+		StreamData{Stream: Stderr, Data: []byte("3\n")},
+		StreamData{Stream: Stdout, Data: []byte("\n4")},
+	))
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{`1`, `2`, `3`, ``, `4`}, execution.OutputLines())
+}
+
+func TestStreamTransformIsAppliedBeforeCaptureAndLogging(t *testing.T) {
+	logged := []string{}
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Stdout || stream == Stderr {
+			logged = append(logged, string(stream)+" "+string(data))
+		}
+	}
+
+	stdout, stderr, err := NewExec(
+		logger,
+		exec.Command(`sh`, `-c`, `echo hello; echo world >&2`),
+	).
+		SetStreamTransform(func(stream Stream, chunk []byte) []byte {
+			if stream == Stdout {
+				return bytes.ToUpper(chunk)
+			}
+
+			return chunk
+		}).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO\n", string(stdout))
+	assert.Equal(t, "world\n", string(stderr))
+	assert.ElementsMatch(t, []string{"stdout HELLO", "stderr world"}, logged)
+}
+
+func TestOutputEncodingConvertsLatin1ToUTF8(t *testing.T) {
+	logged := []string{}
+
+	logger := func(command []string, stream Stream, data []byte) {
+		if stream == Stdout {
+			logged = append(logged, string(data))
+		}
+	}
+
+	stdout, _, err := NewExec(logger, exec.Command(`printf`, `caf\351\n`)).
+		SetOutputEncoding(charmap.ISO8859_1).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "café\n", string(stdout))
+	assert.Equal(t, []string{"café"}, logged)
+}
+
+func TestFilterStreamsReturnsMatchingChunks(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 1; echo 2 >&2; echo 3; echo 4 >&2`,
+		),
+	)
+
+	err := execution.Run()
+	assert.NoError(t, err)
+
+	stderr := execution.FilterStreams(func(data StreamData) bool {
+		return data.Stream == Stderr
+	})
+
+	var lines []string
+	for _, data := range stderr {
+		assert.Equal(t, Stderr, data.Stream)
+
+		lines = append(lines, string(data.Data))
+	}
+
+	assert.Equal(t, "2\n4\n", strings.Join(lines, ""))
+}
+
+func TestSingleWriterOrderingPreservesWriteOrderWithTransform(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		execution := NewExec(
+			nil,
+			exec.Command(`sh`, `-c`, `echo a; echo b >&2; echo c; echo d >&2`),
+		).
+			SetSingleWriterOrdering(true).
+			SetStreamTransform(func(stream Stream, chunk []byte) []byte {
+				return bytes.ToUpper(chunk)
+			})
+
+		err := execution.Run()
+		assert.NoError(t, err)
+
+		var combined []byte
+		for _, data := range execution.GetStreamsData() {
+			combined = append(combined, data.Data...)
+		}
+
+		assert.Equal(t, "A\nB\nC\nD\n", string(combined))
+	}
+}
@@ -0,0 +1,58 @@
+package lexec
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuspendStopsOutputUntilResumed(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`i=0; while true; do i=$((i+1)); echo $i; sleep 0.02; done`,
+		),
+	)
+
+	assert.Error(t, execution.Suspend())
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	count := func() int {
+		chunks := 0
+
+		execution.RangeStreams(func(StreamData) bool {
+			chunks++
+			return true
+		})
+
+		return chunks
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = execution.Suspend()
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	suspended := count()
+
+	time.Sleep(200 * time.Millisecond)
+
+	assert.Equal(t, suspended, count())
+
+	err = execution.Resume()
+	assert.NoError(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	assert.Greater(t, count(), suspended)
+
+	_ = execution.Process().Kill()
+	_ = execution.Wait()
+}
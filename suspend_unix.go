@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package lexec
+
+import (
+	"syscall"
+)
+
+// Suspend stops started command process by sending SIGSTOP. Supported only
+// on Unix.
+func (execution *Execution) Suspend() error {
+	return execution.Signal(syscall.SIGSTOP)
+}
+
+// Resume continues command process suspended via Suspend by sending SIGCONT.
+// Supported only on Unix.
+func (execution *Execution) Resume() error {
+	return execution.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,25 @@
+package lexec
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// Suspend stops started command process by sending SIGSTOP. Supported only
+// on Unix.
+func (execution *Execution) Suspend() error {
+	return karma.Format(
+		nil,
+		`suspend is not supported on this platform: %s`,
+		execution.String(),
+	)
+}
+
+// Resume continues command process suspended via Suspend by sending SIGCONT.
+// Supported only on Unix.
+func (execution *Execution) Resume() error {
+	return karma.Format(
+		nil,
+		`resume is not supported on this platform: %s`,
+		execution.String(),
+	)
+}
@@ -0,0 +1,132 @@
+package lexec
+
+import (
+	"io"
+	"sync"
+
+	"github.com/reconquest/karma-go"
+)
+
+// TeePipe returns reader that receives output of specified stream (Stdout or
+// Stderr) while command is running. Unlike StdoutPipe and StderrPipe, output
+// is still captured and logged.
+//
+// Reader should be read concurrently with Wait, otherwise command will block
+// on writing output. Reader returns EOF when Wait is finished. Must be
+// called before Start.
+func (execution *Execution) TeePipe(stream Stream) (io.Reader, error) {
+	if execution.started {
+		return nil, karma.Format(
+			nil,
+			`can't create tee pipe for already started command: %s`,
+			execution.String(),
+		)
+	}
+
+	if stream != Stdout && stream != Stderr {
+		return nil, karma.Format(
+			nil,
+			`can't create tee pipe for stream %q, only %q and %q supported`,
+			stream, Stdout, Stderr,
+		)
+	}
+
+	reader, writer := io.Pipe()
+
+	if execution.tees == nil {
+		execution.tees = map[Stream][]*io.PipeWriter{}
+	}
+
+	execution.tees[stream] = append(execution.tees[stream], writer)
+
+	return reader, nil
+}
+
+// teeWriter ignores errors of the underlying writer, so output capturing
+// continues even if tee pipe reader has been closed.
+type teeWriter struct {
+	writer io.Writer
+}
+
+func (tee teeWriter) Write(data []byte) (int, error) {
+	_, _ = tee.writer.Write(data)
+
+	return len(data), nil
+}
+
+// CombinedReader returns reader that receives stdout and stderr of the
+// command interleaved in order of arrival while command is running. Output is
+// still captured and logged.
+//
+// Ordering between stdout and stderr is best-effort, since they are read by
+// separate goroutines. Same as TeePipe, reader should be read concurrently
+// with Wait and must be obtained before Start.
+func (execution *Execution) CombinedReader() io.Reader {
+	reader, writer := io.Pipe()
+
+	if execution.started {
+		writer.CloseWithError(karma.Format(
+			nil,
+			`can't create combined reader for already started command: %s`,
+			execution.String(),
+		))
+
+		return reader
+	}
+
+	if execution.tees == nil {
+		execution.tees = map[Stream][]*io.PipeWriter{}
+	}
+
+	for _, stream := range []Stream{Stdout, Stderr} {
+		execution.tees[stream] = append(execution.tees[stream], writer)
+	}
+
+	return reader
+}
+
+// OutputPipe starts command and returns reader over its stdout and stderr
+// interleaved same as CombinedReader. Close of returned reader waits for
+// command to finish and returns result of Wait, like http.Response.Body.
+//
+// Output which is not read before Close is discarded, but still captured and
+// logged.
+func (execution *Execution) OutputPipe() (io.ReadCloser, error) {
+	reader := execution.CombinedReader()
+
+	err := execution.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- execution.Wait()
+	}()
+
+	return &outputPipe{
+		Reader: reader,
+		done:   done,
+	}, nil
+}
+
+type outputPipe struct {
+	io.Reader
+	done chan error
+	err  error
+	once sync.Once
+}
+
+func (pipe *outputPipe) Close() error {
+	pipe.once.Do(func() {
+		// unblocks command if output is not read till the end
+		if closer, ok := pipe.Reader.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		pipe.err = <-pipe.done
+	})
+
+	return pipe.err
+}
@@ -0,0 +1,116 @@
+package lexec
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeePipeKeepsCaptureAndLogging(t *testing.T) {
+	var logged []string
+
+	execution := NewExec(
+		func(command []string, stream Stream, data []byte) {
+			if stream == Stdout {
+				logged = append(logged, string(data))
+			}
+		},
+		exec.Command(`sh`, `-c`, `echo 1; echo 2`),
+	)
+
+	tee, err := execution.TeePipe(Stdout)
+	assert.NoError(t, err)
+
+	err = execution.Start()
+	assert.NoError(t, err)
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(tee)
+		done <- data
+	}()
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+
+	teed := <-done
+
+	stdout, err := ioutil.ReadAll(execution.GetStdout())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1\n2\n", string(teed))
+	assert.Equal(t, "1\n2\n", string(stdout))
+	assert.Equal(t, "1\n2", strings.Join(logged, "\n"))
+	assert.NotEmpty(t, execution.GetStreamsData())
+}
+
+func TestTeePipeRejectsUnsupportedStream(t *testing.T) {
+	_, err := NewExec(nil, exec.Command(`true`)).TeePipe(Finish)
+	assert.Error(t, err)
+}
+
+func TestCombinedReaderInterleavesOutputLive(t *testing.T) {
+	execution := NewExec(
+		nil,
+		exec.Command(
+			`sh`, `-c`,
+			`echo 1; echo 2 >&2; echo 3`,
+		),
+	).SetSingleWriterOrdering(true)
+
+	combined := execution.CombinedReader()
+
+	err := execution.Start()
+	assert.NoError(t, err)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(combined)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	assert.Equal(t, `1`, <-lines)
+	assert.Equal(t, `2`, <-lines)
+	assert.Equal(t, `3`, <-lines)
+
+	err = execution.Wait()
+	assert.NoError(t, err)
+
+	_, ok := <-lines
+	assert.False(t, ok)
+}
+
+func TestOutputPipeCloseReturnsRunError(t *testing.T) {
+	output, err := NewExec(
+		nil,
+		exec.Command(`sh`, `-c`, `echo 1; echo 2 >&2; exit 3`),
+	).OutputPipe()
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadAll(output)
+	assert.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]string{"1", "2"},
+		strings.Fields(string(data)),
+	)
+
+	err = output.Close()
+	assert.True(t, IsExitStatus(err))
+	assert.Equal(t, 3, GetExitStatus(err))
+}
+
+func TestOutputPipeCloseDoesNotBlockOnUnreadOutput(t *testing.T) {
+	output, err := NewExec(nil, exec.Command(`seq`, `100000`)).OutputPipe()
+	assert.NoError(t, err)
+
+	assert.NoError(t, output.Close())
+}
@@ -0,0 +1,58 @@
+package lexec
+
+import (
+	"context"
+)
+
+type traceIDKey struct{}
+
+// ContextWithTraceID returns context which carries given trace ID. It is
+// read by default trace ID extractor used by SetTraceContext.
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext returns trace ID stored in context by
+// ContextWithTraceID or empty string if there is none.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+
+	return id
+}
+
+// SetTraceContext sets context of the request which runs the command. Trace
+// ID extracted from context is passed along with every logged event as
+// `trace` field to the logger set via SetFieldsLogger, so subprocess logs can
+// be correlated with request traces. LoggerfFields appends it to every line
+// and LoggerSlogFields adds it as attribute, same as fields set via
+// SetLogContext.
+//
+// By default trace ID is obtained via TraceIDFromContext, use SetTraceIDFunc
+// to extract it from tracing library span instead.
+func (execution *Execution) SetTraceContext(ctx context.Context) *Execution {
+	execution.traceContext = ctx
+
+	return execution
+}
+
+// SetTraceIDFunc sets function which extracts trace ID from context set via
+// SetTraceContext.
+func (execution *Execution) SetTraceIDFunc(
+	extract func(context.Context) string,
+) *Execution {
+	execution.traceIDFunc = extract
+
+	return execution
+}
+
+func (execution *Execution) getTraceID() string {
+	if execution.traceContext == nil {
+		return ""
+	}
+
+	if execution.traceIDFunc != nil {
+		return execution.traceIDFunc(execution.traceContext)
+	}
+
+	return TraceIDFromContext(execution.traceContext)
+}
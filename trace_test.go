@@ -0,0 +1,64 @@
+package lexec
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTraceContextPassesTraceIDAsField(t *testing.T) {
+	log := []string{}
+
+	logger := func(format string, data ...interface{}) {
+		log = append(log, fmt.Sprintf(format, data...))
+	}
+
+	err := NewExec(nil, exec.Command(`echo`, `1`)).
+		SetFieldsLogger(LoggerfFields(logger)).
+		SetTraceContext(ContextWithTraceID(context.Background(), `abc123`)).
+		SetLogContext(map[string]string{`host`: `web-1`}).
+		Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`launch | echo 1 [trace=abc123 host=web-1]`,
+		`stdout |  1 [trace=abc123 host=web-1]`,
+		`finish | echo 1 -> exit 0 [trace=abc123 host=web-1]`,
+	}, log)
+}
+
+func TestSetTraceIDFuncExtractsTraceIDWithCustomFunc(t *testing.T) {
+	type spanKey struct{}
+
+	var traces []string
+
+	err := NewExec(nil, exec.Command(`echo`, `[trace:x] 1`)).
+		SetFieldsLogger(func(
+			command []string,
+			stream Stream,
+			data []byte,
+			fields []LogField,
+		) {
+			if stream == Stdout {
+				assert.Equal(t, `[trace:x] 1`, string(data))
+			}
+
+			for _, field := range fields {
+				if field.Key == `trace` {
+					traces = append(traces, field.Value)
+				}
+			}
+		}).
+		SetTraceContext(
+			context.WithValue(context.Background(), spanKey{}, `span-1`),
+		).
+		SetTraceIDFunc(func(ctx context.Context) string {
+			return ctx.Value(spanKey{}).(string)
+		}).
+		Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`span-1`, `span-1`, `span-1`}, traces)
+}
@@ -0,0 +1,45 @@
+package lexec
+
+import (
+	"github.com/reconquest/karma-go"
+)
+
+// SetUmask sets file mode creation mask which will be used by the command.
+//
+// Supported only on Linux and only for commands created via NewExec,
+// otherwise Start returns error. Umask of the current process is set to
+// given mask while command is started and restored afterwards, so files
+// created by other goroutines of the current process at the same time are
+// created with given mask too.
+func (execution *Execution) SetUmask(mask int) *Execution {
+	execution.mustNotBeStarted(`SetUmask`)
+
+	execution.umask = mask
+	execution.hasUmask = true
+
+	return execution
+}
+
+func (execution *Execution) checkUmask() error {
+	if !execution.hasUmask {
+		return nil
+	}
+
+	if !umaskSupported {
+		return karma.Format(
+			nil,
+			`umask is not supported on this platform: %s`,
+			execution.String(),
+		)
+	}
+
+	if _, ok := execution.command.(*command); !ok {
+		return karma.Format(
+			nil,
+			`umask can be set only for local command: %s`,
+			execution.String(),
+		)
+	}
+
+	return nil
+}
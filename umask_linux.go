@@ -0,0 +1,22 @@
+package lexec
+
+import (
+	"sync"
+	"syscall"
+)
+
+const umaskSupported = true
+
+// umaskMutex serializes starts of commands with umask, since umask is
+// shared by the whole process.
+var umaskMutex sync.Mutex
+
+func startWithUmask(mask int, start func() error) error {
+	umaskMutex.Lock()
+	defer umaskMutex.Unlock()
+
+	previous := syscall.Umask(mask)
+	defer syscall.Umask(previous)
+
+	return start()
+}
@@ -0,0 +1,42 @@
+package lexec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUmaskIsAppliedToCreatedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), `file`)
+
+	err := NewExec(nil, exec.Command(`touch`, path)).
+		SetUmask(0077).
+		Run()
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestUmaskDoesNotChangeUmaskOfCurrentProcess(t *testing.T) {
+	previous := syscall.Umask(0022)
+	defer syscall.Umask(previous)
+
+	err := NewExec(nil, exec.Command(`true`)).SetUmask(0077).Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0022, syscall.Umask(0022))
+}
+
+func TestUmaskKeepsArgv(t *testing.T) {
+	stdout, _, err := NewExec(nil, exec.Command(`sh`, `-c`, `echo $0`)).
+		SetUmask(0077).
+		Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "sh\n", string(stdout))
+}
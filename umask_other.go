@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package lexec
+
+const umaskSupported = false
+
+func startWithUmask(mask int, start func() error) error {
+	return start()
+}
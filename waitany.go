@@ -0,0 +1,84 @@
+package lexec
+
+import (
+	"errors"
+
+	"github.com/reconquest/karma-go"
+)
+
+var errNoExecutions = errors.New(`no executions to wait for`)
+
+// WaitAny waits for the first of given started executions to finish and
+// returns its index and result of Wait. Returns error with index -1 if no or
+// nil executions are given.
+//
+// Remaining executions are left running and are waited in background; Wait
+// still can be called for them and returns the same result. Use WaitAnyKill
+// to stop them.
+func WaitAny(executions ...*Execution) (int, error) {
+	err := validateWaitAny(executions)
+	if err != nil {
+		return -1, err
+	}
+
+	result, _ := waitAny(executions)
+
+	return result.index, result.err
+}
+
+// WaitAnyKill is same as WaitAny, but kills remaining executions and waits
+// until they are finished before returning.
+func WaitAnyKill(executions ...*Execution) (int, error) {
+	err := validateWaitAny(executions)
+	if err != nil {
+		return -1, err
+	}
+
+	result, done := waitAny(executions)
+
+	for index, execution := range executions {
+		if index != result.index {
+			_ = execution.Kill()
+		}
+	}
+
+	for i := 1; i < len(executions); i++ {
+		<-done
+	}
+
+	return result.index, result.err
+}
+
+func validateWaitAny(executions []*Execution) error {
+	if len(executions) == 0 {
+		return errNoExecutions
+	}
+
+	for index, execution := range executions {
+		if execution == nil {
+			return karma.Describe("index", index).Format(
+				nil,
+				`execution to wait for is nil`,
+			)
+		}
+	}
+
+	return nil
+}
+
+type waitResult struct {
+	index int
+	err   error
+}
+
+func waitAny(executions []*Execution) (waitResult, <-chan waitResult) {
+	done := make(chan waitResult, len(executions))
+
+	for index, execution := range executions {
+		go func(index int, execution *Execution) {
+			done <- waitResult{index: index, err: execution.Wait()}
+		}(index, execution)
+	}
+
+	return <-done, done
+}
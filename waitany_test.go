@@ -0,0 +1,58 @@
+package lexec
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitAnyReturnsFirstFinishedExecution(t *testing.T) {
+	slow := NewExec(nil, exec.Command(`sleep`, `0.5`))
+	fast := NewExec(nil, exec.Command(`false`))
+
+	assert.NoError(t, slow.Start())
+	assert.NoError(t, fast.Start())
+
+	index, err := WaitAny(slow, fast)
+	assert.Equal(t, 1, index)
+	assert.True(t, IsExitStatus(err))
+}
+
+func TestWaitAnyKillKillsRemainingExecutions(t *testing.T) {
+	slow := NewExec(nil, exec.Command(`sleep`, `10`))
+	fast := NewExec(nil, exec.Command(`true`))
+
+	startedAt := time.Now()
+
+	assert.NoError(t, slow.Start())
+	assert.NoError(t, fast.Start())
+
+	index, err := WaitAnyKill(slow, fast)
+	assert.Equal(t, 1, index)
+	assert.NoError(t, err)
+	assert.True(t, slow.WasKilled())
+	assert.Less(t, time.Since(startedAt), 5*time.Second)
+}
+
+func TestWaitAnyReturnsErrorForNoExecutions(t *testing.T) {
+	index, err := WaitAny()
+	assert.Equal(t, -1, index)
+	assert.Error(t, err)
+}
+
+func TestWaitAnyReturnsErrorForNilExecution(t *testing.T) {
+	execution := NewExec(nil, exec.Command(`true`))
+	assert.NoError(t, execution.Start())
+
+	index, err := WaitAny(execution, nil)
+	assert.Equal(t, -1, index)
+	assert.Contains(t, err.Error(), `execution to wait for is nil`)
+
+	index, err = WaitAnyKill(nil)
+	assert.Equal(t, -1, index)
+	assert.Error(t, err)
+
+	assert.NoError(t, execution.Wait())
+}